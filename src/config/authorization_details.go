@@ -0,0 +1,151 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+func init() {
+	RegisterCustomParam("granted_authorization_details", GrantAuthorizationDetails)
+}
+
+// GrantAuthorizationDetails returns the authorization_details (RFC 9396)
+// granted for the request as a JSON array, based on the
+// AuthorizationDetailsConfig. Nothing is returned if no details are granted.
+func GrantAuthorizationDetails(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	requested, err := requestedAuthorizationDetails(input)
+	if err != nil {
+		return nil, err
+	}
+
+	granted, err := grantAuthorizationDetails(input, &config.AuthorizationDetailsConfig, requested)
+	if err != nil || len(granted) == 0 {
+		return nil, err
+	}
+
+	b, err := json.Marshal(granted)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(b)}, nil
+}
+
+// requestedAuthorizationDetails returns the details requested at the
+// Authorization Endpoint and in the token request. A token request can only
+// narrow the details of the session (RFC 9396 section 6.1), so its details
+// that were not requested at the Authorization Endpoint are left out.
+func requestedAuthorizationDetails(input *sessionmgmt.RequestInput) ([]map[string]any, error) {
+	token, err := sessionmgmt.ParseAuthorizationDetails(input.FormParams.Get("authorization_details"))
+	if err != nil {
+		return nil, err
+	}
+	if input.Session == nil || len(input.Session.AuthorizationDetails) == 0 {
+		return token, nil
+	}
+	if token == nil {
+		return input.Session.AuthorizationDetails, nil
+	}
+
+	requested := []map[string]any{}
+	for _, detail := range token {
+		for _, authorized := range input.Session.AuthorizationDetails {
+			if reflect.DeepEqual(detail, authorized) {
+				requested = append(requested, detail)
+				break
+			}
+		}
+	}
+	return requested, nil
+}
+
+// grantAuthorizationDetails applies the grant mode and then the first rule
+// matching each detail type to the requested details.
+func grantAuthorizationDetails(input *sessionmgmt.RequestInput, c *AuthorizationDetailsConfig, requested []map[string]any) ([]map[string]any, error) {
+	if len(requested) == 0 || c.Grant == "drop" {
+		return nil, nil
+	}
+
+	granted := []map[string]any{}
+	for _, detail := range requested {
+		copied := make(map[string]any, len(detail))
+		for key, val := range detail {
+			// Misbehave by granting only the first value of each list.
+			if list, ok := val.([]any); ok && c.Grant == "narrow" && len(list) > 1 {
+				val = list[:1]
+			}
+			copied[key] = val
+		}
+		granted = append(granted, copied)
+	}
+
+	// Misbehave by granting details that were not requested.
+	if c.Grant == "widen" && c.AdditionalDetails != "" {
+		additional, err := sessionmgmt.ParseAuthorizationDetails(c.AdditionalDetails)
+		if err != nil {
+			return nil, fmt.Errorf("invalid additional details: %v", err)
+		}
+		granted = append(granted, additional...)
+	}
+
+	rewritten := []map[string]any{}
+	for _, detail := range granted {
+		detail, err := rewriteAuthorizationDetail(input, c.Rules, detail)
+		if err != nil {
+			return nil, err
+		}
+		if detail != nil {
+			rewritten = append(rewritten, detail)
+		}
+	}
+	return rewritten, nil
+}
+
+// rewriteAuthorizationDetail applies the first rule for the detail type. The
+// rule value is a template evaluating to a JSON object that is merged into or
+// replaces the detail, and other values are an error. A nil detail is dropped.
+func rewriteAuthorizationDetail(input *sessionmgmt.RequestInput, rules []AuthorizationDetailsRule, detail map[string]any) (map[string]any, error) {
+	for _, rule := range rules {
+		if rule.Type != detail["type"] {
+			continue
+		}
+
+		switch rule.Action {
+		case "drop":
+			return nil, nil
+		case "merge", "replace":
+			p := Parameter{ID: rule.Type, Action: "set", Values: []string{rule.Value}, JSONType: "object"}
+			val, err := p.GetJSON(input)
+			if err != nil {
+				return nil, err
+			}
+			obj, ok := val.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("rule for %q is not a JSON object: %v", rule.Type, val)
+			}
+			if rule.Action == "replace" {
+				return obj, nil
+			}
+			for key, v := range obj {
+				detail[key] = v
+			}
+		}
+		return detail, nil
+	}
+	return detail, nil
+}
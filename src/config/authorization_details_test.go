@@ -0,0 +1,154 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestGrantAuthorizationDetails(t *testing.T) {
+	requested := []map[string]any{
+		{"type": "payment_initiation", "actions": []any{"initiate", "status"}, "instructedAmount": map[string]any{"currency": "EUR", "amount": "123.50"}},
+		{"type": "account_information", "actions": []any{"list_accounts"}},
+	}
+	additional := `[{"type":"account_information","actions":["read_transactions"]}]`
+
+	cases := []struct {
+		title     string
+		config    AuthorizationDetailsConfig
+		requested []map[string]any
+		form      url.Values
+		want      []map[string]any
+	}{
+		{
+			title:     "Echo",
+			config:    AuthorizationDetailsConfig{Grant: "echo"},
+			requested: requested,
+			want:      requested,
+		},
+		{
+			title:  "Echo token request details",
+			config: AuthorizationDetailsConfig{Grant: "echo"},
+			form:   url.Values{"authorization_details": {`[{"type":"account_information"}]`}},
+			want:   []map[string]any{{"type": "account_information"}},
+		},
+		{
+			title:     "Token request narrows session details",
+			config:    AuthorizationDetailsConfig{Grant: "echo"},
+			requested: requested,
+			form:      url.Values{"authorization_details": {`[{"type":"account_information","actions":["list_accounts"]},{"type":"account_information","actions":["read_transactions"]}]`}},
+			want:      requested[1:],
+		},
+		{
+			title:     "Narrow",
+			config:    AuthorizationDetailsConfig{Grant: "narrow"},
+			requested: requested,
+			want: []map[string]any{
+				{"type": "payment_initiation", "actions": []any{"initiate"}, "instructedAmount": map[string]any{"currency": "EUR", "amount": "123.50"}},
+				{"type": "account_information", "actions": []any{"list_accounts"}},
+			},
+		},
+		{
+			title:     "Widen",
+			config:    AuthorizationDetailsConfig{Grant: "widen", AdditionalDetails: additional},
+			requested: requested[1:],
+			want: []map[string]any{
+				{"type": "account_information", "actions": []any{"list_accounts"}},
+				{"type": "account_information", "actions": []any{"read_transactions"}},
+			},
+		},
+		{
+			title:     "Drop",
+			config:    AuthorizationDetailsConfig{Grant: "drop"},
+			requested: requested,
+		},
+		{
+			title:  "Nothing requested",
+			config: AuthorizationDetailsConfig{Grant: "widen", AdditionalDetails: additional},
+		},
+		{
+			title: "Merge rule",
+			config: AuthorizationDetailsConfig{Grant: "echo", Rules: []AuthorizationDetailsRule{
+				{Type: "payment_initiation", Action: "merge", Value: `{"instructedAmount":{"currency":"EUR","amount":"9999.00"}}`},
+			}},
+			requested: requested[:1],
+			want: []map[string]any{
+				{"type": "payment_initiation", "actions": []any{"initiate", "status"}, "instructedAmount": map[string]any{"currency": "EUR", "amount": "9999.00"}},
+			},
+		},
+		{
+			title: "Replace and drop rules",
+			config: AuthorizationDetailsConfig{Grant: "echo", Rules: []AuthorizationDetailsRule{
+				{Type: "payment_initiation", Action: "replace", Value: `{"type":"payment_initiation","actions":["cancel"]}`},
+				{Type: "account_information", Action: "drop"},
+			}},
+			requested: requested,
+			want:      []map[string]any{{"type": "payment_initiation", "actions": []any{"cancel"}}},
+		},
+		{
+			title: "Keep rule",
+			config: AuthorizationDetailsConfig{Grant: "echo", Rules: []AuthorizationDetailsRule{
+				{Type: "account_information", Action: "keep"},
+				{Type: "account_information", Action: "drop"},
+			}},
+			requested: requested[1:],
+			want:      requested[1:],
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			input := &sessionmgmt.RequestInput{
+				Session:    &sessionmgmt.Session{AuthorizationDetails: tc.requested},
+				FormParams: tc.form,
+			}
+			c := DefaultConfig
+			c.AuthorizationDetailsConfig = tc.config
+
+			vals, err := GrantAuthorizationDetails(input, &c)
+			if err != nil {
+				t.Fatalf("GrantAuthorizationDetails() failed: %v", err)
+			}
+
+			var got []map[string]any
+			if len(vals) > 0 {
+				if err := json.Unmarshal([]byte(vals[0]), &got); err != nil {
+					t.Fatalf("GrantAuthorizationDetails() returned invalid JSON %q: %v", vals[0], err)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("GrantAuthorizationDetails() returned %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGrantAuthorizationDetailsInvalidRule(t *testing.T) {
+	input := &sessionmgmt.RequestInput{
+		Session: &sessionmgmt.Session{AuthorizationDetails: []map[string]any{{"type": "payment_initiation"}}},
+	}
+	c := DefaultConfig
+	c.AuthorizationDetailsConfig = AuthorizationDetailsConfig{Grant: "echo", Rules: []AuthorizationDetailsRule{
+		{Type: "payment_initiation", Action: "replace", Value: `["cancel"]`},
+	}}
+
+	if vals, err := GrantAuthorizationDetails(input, &c); err == nil {
+		t.Errorf("GrantAuthorizationDetails() returned %v, expected an error for a rule that is not an object", vals)
+	}
+}
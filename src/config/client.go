@@ -0,0 +1,111 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"slices"
+)
+
+// GetClient returns the configured client with the client ID, or the client
+// registered with that client ID at the registration endpoint.
+func (c *Config) GetClient(clientID string) (Client, bool) {
+	for _, client := range c.Clients {
+		if client.ClientID == clientID {
+			return client, true
+		}
+	}
+
+	registered, err := sessionmgmt.GetRegisteredClient(clientID)
+	if err != nil {
+		return Client{}, false
+	}
+	return clientFromMetadata(registered), true
+}
+
+// AllClients returns the configured clients followed by the clients registered at
+// the registration endpoint. Configured clients take precedence over registered
+// clients with the same client ID, as in GetClient.
+func (c *Config) AllClients() []Client {
+	clients := slices.Clone(c.Clients)
+	for _, registered := range sessionmgmt.GetRegisteredClients() {
+		if !slices.ContainsFunc(c.Clients, func(client Client) bool { return client.ClientID == registered.ClientID }) {
+			clients = append(clients, clientFromMetadata(registered))
+		}
+	}
+	return clients
+}
+
+// AllowsPostLogoutRedirect checks the URI is one of the client's post logout redirect URIs.
+func (c Client) AllowsPostLogoutRedirect(uri string) bool {
+	return slices.Contains(c.PostLogoutRedirectURIs, uri)
+}
+
+// AllowsRedirect checks the URI is one of the client's redirect URIs, or the
+// redirect URI check is skipped.
+func (c Client) AllowsRedirect(uri string) bool {
+	return c.SkipRedirectURICheck || slices.Contains(c.RedirectURIs, uri)
+}
+
+// clientFromMetadata converts the client information of a registered client.
+// The token endpoint auth method defaults to client_secret_basic as in RFC 7591.
+func clientFromMetadata(registered sessionmgmt.RegisteredClient) Client {
+	m := registered.Metadata
+	client := Client{
+		ClientID:                    registered.ClientID,
+		ClientSecret:                registered.ClientSecret,
+		RedirectURIs:                metadataStrings(m["redirect_uris"]),
+		TokenEndpointAuthMethod:     "client_secret_basic",
+		TokenEndpointAuthSigningAlg: metadataString(m["token_endpoint_auth_signing_alg"]),
+		TLSClientAuthSubjectDN:      metadataString(m["tls_client_auth_subject_dn"]),
+		IDTokenSignedResponseAlg:    metadataString(m["id_token_signed_response_alg"]),
+		PostLogoutRedirectURIs:      metadataStrings(m["post_logout_redirect_uris"]),
+		FrontchannelLogoutURI:       metadataString(m["frontchannel_logout_uri"]),
+		BackchannelLogoutURI:        metadataString(m["backchannel_logout_uri"]),
+	}
+
+	if method := metadataString(m["token_endpoint_auth_method"]); method != "" {
+		client.TokenEndpointAuthMethod = method
+	}
+	if jwks, ok := m["jwks"]; ok {
+		if b, err := json.Marshal(jwks); err == nil {
+			client.JWKS = string(b)
+		}
+	}
+	return client
+}
+
+// metadataString returns a string metadata value, or an empty string for other types.
+func metadataString(val any) string {
+	s, _ := val.(string)
+	return s
+}
+
+// metadataStrings returns the strings of an array metadata value.
+func metadataStrings(val any) []string {
+	var values []string
+	switch v := val.(type) {
+	case []string:
+		return v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"reflect"
+	"testing"
+)
+
+func TestGetClient(t *testing.T) {
+	c := Config{Clients: []Client{
+		{ClientID: "first", PostLogoutRedirectURIs: []string{"https://first.example/loggedout"}},
+		{ClientID: "second"},
+	}}
+
+	cases := []struct {
+		title    string
+		clientID string
+		uri      string
+		found    bool
+		allowed  bool
+	}{
+		{title: "Registered URI", clientID: "first", uri: "https://first.example/loggedout", found: true, allowed: true},
+		{title: "Unregistered URI", clientID: "first", uri: "https://evil.example/", found: true},
+		{title: "No URIs registered", clientID: "second", uri: "https://first.example/loggedout", found: true},
+		{title: "Unknown client", clientID: "third", uri: "https://first.example/loggedout"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			client, found := c.GetClient(tc.clientID)
+			if found != tc.found {
+				t.Fatalf("GetClient() found %v, expected %v", found, tc.found)
+			}
+
+			if allowed := client.AllowsPostLogoutRedirect(tc.uri); allowed != tc.allowed {
+				t.Errorf("AllowsPostLogoutRedirect() = %v, expected %v", allowed, tc.allowed)
+			}
+		})
+	}
+}
+
+func TestGetRegisteredClient(t *testing.T) {
+	sessionmgmt.AddRegisteredClient(sessionmgmt.RegisteredClient{
+		ClientID:     "dynamic",
+		ClientSecret: "s3cret",
+		Metadata: map[string]any{
+			"redirect_uris":              []any{"https://dynamic.example/cb"},
+			"token_endpoint_auth_method": "private_key_jwt",
+			"jwks":                       map[string]any{"keys": []any{}},
+		},
+	})
+
+	client, ok := (&Config{}).GetClient("dynamic")
+	if !ok {
+		t.Fatalf("GetClient() did not find the registered client")
+	}
+
+	want := Client{
+		ClientID:                "dynamic",
+		ClientSecret:            "s3cret",
+		RedirectURIs:            []string{"https://dynamic.example/cb"},
+		JWKS:                    `{"keys":[]}`,
+		TokenEndpointAuthMethod: "private_key_jwt",
+	}
+	if !reflect.DeepEqual(client, want) {
+		t.Errorf("GetClient() returned %+v, expected %+v", client, want)
+	}
+
+	if !client.AllowsRedirect("https://dynamic.example/cb") || client.AllowsRedirect("https://attacker.example/cb") {
+		t.Errorf("AllowsRedirect() did not match the registered redirect URIs")
+	}
+}
+
+func TestAllClients(t *testing.T) {
+	sessionmgmt.AddRegisteredClient(sessionmgmt.RegisteredClient{ClientID: "shadowed"})
+	sessionmgmt.AddRegisteredClient(sessionmgmt.RegisteredClient{
+		ClientID: "logoutrp",
+		Metadata: map[string]any{"backchannel_logout_uri": "https://logoutrp.example/logout"},
+	})
+	defer sessionmgmt.DeleteRegisteredClient("shadowed")
+	defer sessionmgmt.DeleteRegisteredClient("logoutrp")
+
+	c := Config{Clients: []Client{{ClientID: "static"}, {ClientID: "shadowed", ClientSecret: "configured"}}}
+	found := map[string][]Client{}
+	for _, client := range c.AllClients() {
+		found[client.ClientID] = append(found[client.ClientID], client)
+	}
+
+	if len(found["static"]) != 1 {
+		t.Errorf("AllClients() returned %d configured clients, expected 1", len(found["static"]))
+	}
+	if shadowed := found["shadowed"]; len(shadowed) != 1 || shadowed[0].ClientSecret != "configured" {
+		t.Errorf("AllClients() returned %+v, expected only the configured client", shadowed)
+	}
+	if logoutrp := found["logoutrp"]; len(logoutrp) != 1 || logoutrp[0].BackchannelLogoutURI != "https://logoutrp.example/logout" {
+		t.Errorf("AllClients() returned %+v, expected the registered client", logoutrp)
+	}
+}
@@ -19,13 +19,35 @@ import "sync"
 
 // Config represents the overall configuration.
 type Config struct {
-	AuthAction      AuthAction      `json:"auth_action" jsonschema:"title=Authorization Endpoint Configuration"`
-	TokenAction     TokenAction     `json:"token_action" jsonschema:"title=Token Endpoint Configuration"`
-	UserInfoAction  UserInfoAction  `json:"userinfo_action" jsonschema:"title=UserInfo Endpoint Configuration"`
-	DiscoveryAction DiscoveryAction `json:"discovery_action" jsonschema:"title=Discovery Endpoint Configuration"`
+	AuthAction          AuthAction          `json:"auth_action" jsonschema:"title=Authorization Endpoint Configuration"`
+	TokenAction         TokenAction         `json:"token_action" jsonschema:"title=Token Endpoint Configuration"`
+	UserInfoAction      UserInfoAction      `json:"userinfo_action" jsonschema:"title=UserInfo Endpoint Configuration"`
+	DiscoveryAction     DiscoveryAction     `json:"discovery_action" jsonschema:"title=Discovery Endpoint Configuration"`
+	OAuthMetadataAction OAuthMetadataAction `json:"oauth_metadata_action" jsonschema:"title=OAuth Metadata Endpoint Configuration"`
+	WebFingerAction     WebFingerAction     `json:"webfinger_action" jsonschema:"title=WebFinger Endpoint Configuration"`
+	DeviceAction        DeviceAction        `json:"device_action" jsonschema:"title=Device Authorization Endpoint Configuration"`
+	CIBAAction          CIBAAction          `json:"ciba_action" jsonschema:"title=CIBA Endpoint Configuration"`
+	PARAction           PARAction           `json:"par_action" jsonschema:"title=Pushed Authorization Request Endpoint Configuration"`
+	IntrospectionAction IntrospectionAction `json:"introspection_action" jsonschema:"title=Introspection Endpoint Configuration"`
+	RevocationAction    RevocationAction    `json:"revocation_action" jsonschema:"title=Revocation Endpoint Configuration"`
+	LogoutAction        LogoutAction        `json:"logout_action" jsonschema:"title=End Session Endpoint Configuration"`
+	RegistrationAction  RegistrationAction  `json:"registration_action" jsonschema:"title=Registration Endpoint Configuration"`
+
+	// Clients registered with the IdP.
+	Clients []Client `json:"clients" jsonschema:"title=Clients"`
 
 	// Custom Parameter Config Entries.
-	IDTokenConfig IDTokenConfig `json:"id_token_config" jsonschema:"title=ID Token Config"`
+	IDTokenConfig       IDTokenConfig `json:"id_token_config" jsonschema:"title=ID Token Config"`
+	ExchangeTokenConfig IDTokenConfig `json:"exchange_token_config" jsonschema:"title=Exchanged Token Config"`
+	AccessTokenConfig   IDTokenConfig `json:"access_token_config" jsonschema:"title=JWT Access Token Config"`
+	JARMConfig          JARMConfig    `json:"jarm_config" jsonschema:"title=JARM Response Config"`
+
+	BackchannelLogoutConfig BackchannelLogoutConfig `json:"backchannel_logout_config" jsonschema:"title=Back-Channel Logout Config"`
+	DPoPConfig              DPoPConfig              `json:"dpop_config" jsonschema:"title=DPoP Config"`
+	MTLSConfig              MTLSConfig              `json:"mtls_config" jsonschema:"title=Mutual TLS Config"`
+
+	AuthorizationDetailsConfig AuthorizationDetailsConfig `json:"authorization_details_config" jsonschema:"title=Authorization Details Config"`
+	ResourceConfig             ResourceConfig             `json:"resource_config" jsonschema:"title=Resource Indicators Config"`
 }
 
 // AuthAction configures the authz endpoint.
@@ -34,6 +56,28 @@ type AuthAction struct {
 	Redirect AuthRedirect `json:"redirect" jsonschema:"title=Redirect Config" jsonschema_extras:"hide=action_type !== redirect"`
 	Error    Error        `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
 	// Block doesn't have any parameters.
+
+	// RequestObject controls handling of request and request_uri request objects.
+	RequestObject RequestObjectConfig `json:"request_object" jsonschema:"title=Request Object Config"`
+}
+
+// RequestObjectConfig configures handling of JWT-Secured Authorization Requests.
+// Request object claims are merged into the URL parameters before the redirect
+// is built.
+type RequestObjectConfig struct {
+	FetchRequestURI   bool        `json:"fetch_request_uri" jsonschema:"title=Fetch request_uri URLs"`
+	RequestURIHosts   []string    `json:"request_uri_hosts" jsonschema:"title=Allowed request_uri Hosts"`
+	VerifySignature   bool        `json:"verify_signature" jsonschema:"title=Verify Signature"`
+	ClientKeys        []ClientKey `json:"client_keys" jsonschema:"title=Client Keys"`
+	AcceptUnsigned    bool        `json:"accept_unsigned" jsonschema:"title=Accept Unsigned Request Objects"`
+	Precedence        string      `json:"precedence" jsonschema:"title=Parameter Precedence,enum=request_object,enum=query,default=request_object"`
+	RequireConsistent bool        `json:"require_consistent" jsonschema:"title=Reject Mismatched client_id and response_type"`
+}
+
+// ClientKey is the JSON Key Set of a client.
+type ClientKey struct {
+	ClientID string `json:"client_id" jsonschema:"title=Client ID"`
+	JWKS     string `json:"jwks" jsonschema:"title=Client JSON Key Set"`
 }
 
 // AuthRedirect configures a redirection.
@@ -42,6 +86,14 @@ type AuthRedirect struct {
 	DefaultParamAction string         `json:"default_parameter_action" jsonschema:"title=Default Parameter Action,enum=passthrough,enum=omit"`
 	Parameters         []Parameter    `json:"parameters" jsonschema:"title=Parameters"`
 	UseHashFragment    bool           `json:"use_hash_fragment" jsonschema:"title=Use Hash Fragment"`
+	ResponseMode       string         `json:"response_mode" jsonschema:"title=Response Mode,enum=requested,enum=query,enum=fragment,enum=form_post,enum=web_message,default=requested"`
+	WebMessage         WebMessage     `json:"web_message" jsonschema:"title=Web Message Config"`
+}
+
+// WebMessage configures the postMessage call of the web_message response mode.
+type WebMessage struct {
+	TargetOrigin string `json:"target_origin" jsonschema:"title=Target Origin,enum=redirect_uri,enum=wildcard,enum=custom,default=redirect_uri"`
+	Origin       string `json:"origin" jsonschema:"title=Custom Target Origin" jsonschema_extras:"hide=target_origin !== custom"`
 }
 
 // RedirectTarget is the target of a redirection.
@@ -58,7 +110,7 @@ type Parameter struct {
 	Action    string   `json:"action" jsonschema:"title=Parameter Action,enum=passthrough,enum=set,enum=omit,enum=random,enum=custom,default=passthrough"`
 	Values    []string `json:"values" jsonschema:"title=Values,default=example_value" jsonschema_extras:"hide=action !== set"`
 	CustomKey string   `json:"custom_key" jsonschema:"title=Custom Processor Key,default=test" jsonschema_extras:"hide=action !== custom"`
-	JSONType  string   `json:"json_type" jsonschema:"title=JSON Value Type,enum=string,enum=array,enum=number,enum=boolean,enum=object,default=string"`
+	JSONType  string   `json:"json_type" jsonschema:"title=JSON Value Type,enum=string,enum=array,enum=number,enum=boolean,enum=object,enum=json,default=string"`
 }
 
 // Error represents an error to return.
@@ -69,31 +121,147 @@ type Error struct {
 
 // TokenAction configures the Token endpoint.
 type TokenAction struct {
-	Action  string       `json:"action_type" jsonschema:"title=Token Endpoint Action,enum=respond,enum=error,enum=block"`
+	Action  string       `json:"action_type" jsonschema:"title=Token Endpoint Action,enum=respond,enum=forward,enum=error,enum=block"`
 	Respond TokenRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
-	// TODO Implement Forward TokenForward  `json:"forward"`
-	Error Error `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== 'error'"`
+	Forward TokenForward `json:"forward" jsonschema:"title=Forward Config" jsonschema_extras:"hide=action_type !== forward"`
+	Error   Error        `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== 'error'"`
+	// Block doesn't have any parameters.
+
+	// PKCEMode controls code_verifier validation for respond actions.
+	PKCEMode string `json:"pkce_mode" jsonschema:"title=PKCE Mode,enum=ignore,enum=enforce,enum=accept_any_verifier,enum=accept_plain_downgrade,enum=accept_missing_verifier,default=ignore"`
+
+	// RefreshToken controls the lifecycle of issued refresh tokens.
+	RefreshToken RefreshTokenConfig `json:"refresh_token" jsonschema:"title=Refresh Token Config"`
+
+	// JWTBearer controls assertion validation for JWT Bearer grants.
+	JWTBearer JWTBearerConfig `json:"jwt_bearer" jsonschema:"title=JWT Bearer Assertion Config"`
+
+	// GrantTypes override the action above for specific grant_type values.
+	GrantTypes []GrantTypeAction `json:"grant_types" jsonschema:"title=Grant Type Specific Actions"`
+}
+
+// JWTBearerConfig configures validation of JWT Bearer grant assertions.
+type JWTBearerConfig struct {
+	VerifySignature     bool     `json:"verify_signature" jsonschema:"title=Verify Signature"`
+	JWKS                string   `json:"jwks" jsonschema:"title=Client JSON Key Set"`
+	Audience            []string `json:"audience" jsonschema:"title=Accepted Audience Values"`
+	AcceptExpired       bool     `json:"accept_expired" jsonschema:"title=Accept Expired Assertions"`
+	AcceptUnsigned      bool     `json:"accept_unsigned" jsonschema:"title=Accept Unsigned Assertions"`
+	AcceptWrongAudience bool     `json:"accept_wrong_audience" jsonschema:"title=Accept Assertions for Another Audience"`
+}
+
+// GrantTypeAction configures the Token endpoint for a single grant_type.
+type GrantTypeAction struct {
+	GrantType string       `json:"grant_type" jsonschema:"title=Grant Type,default=authorization_code"`
+	Action    string       `json:"action_type" jsonschema:"title=Token Endpoint Action,enum=respond,enum=forward,enum=error,enum=block,default=respond"`
+	Respond   TokenRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Forward   TokenForward `json:"forward" jsonschema:"title=Forward Config" jsonschema_extras:"hide=action_type !== forward"`
+	Error     Error        `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== 'error'"`
 	// Block doesn't have any parameters.
 }
 
+// RefreshTokenConfig configures how refresh tokens are tracked and accepted.
+type RefreshTokenConfig struct {
+	Mode                 string `json:"mode" jsonschema:"title=Refresh Token Mode,enum=accept_any,enum=rotate,enum=reuse,default=accept_any"`
+	MaxUses              int    `json:"max_uses" jsonschema:"title=Maximum Uses (0 is unlimited)" jsonschema_extras:"hide=mode === accept_any"`
+	AllowReplay          bool   `json:"allow_replay" jsonschema:"title=Accept Replayed Rotated Tokens" jsonschema_extras:"hide=mode !== rotate"`
+	RevokeFamilyOnReplay bool   `json:"revoke_family_on_replay" jsonschema:"title=Revoke Token Family on Replay" jsonschema_extras:"hide=mode !== rotate"`
+}
+
 // TokenRespond configures responding with JSON content.
 type TokenRespond struct {
 	Parameters []Parameter `json:"parameters" jsonschema:"title=Parameters"`
 }
 
+// TokenForward configures relaying the token request to an upstream IdP and
+// modifying its JSON response. A passthrough Parameter keeps the upstream value.
+type TokenForward struct {
+	Target             string      `json:"target" jsonschema:"title=Upstream Token Endpoint URL"`
+	DefaultParamAction string      `json:"default_parameter_action" jsonschema:"title=Default Parameter Action,enum=passthrough,enum=omit"`
+	Parameters         []Parameter `json:"parameters" jsonschema:"title=Parameters"`
+}
+
 // IDTokenConfig configures IDToken responses.
 type IDTokenConfig struct {
 	Algorithm       string  `json:"alg" jsonschema:"title=JWT Signature Algorithm"`
+	Type            string  `json:"typ" jsonschema:"title=JWT Type Header"`
 	RemoveSignature bool    `json:"remove_signature" jsonschema:"title=Remove Signature"`
 	UseWrongKey     bool    `json:"use_wrong_key" jsonschema:"title=Use Incorrect Key"`
 	Claims          []Claim `json:"claims" jsonschema:"title=Claims"`
 }
 
+// JARMConfig configures the JWT Secured Authorization Response Mode response token.
+// The authorization response parameters are added to the token claims.
+type JARMConfig struct {
+	Token      IDTokenConfig `json:"token" jsonschema:"title=Response Token"`
+	Encryption JWEConfig     `json:"encryption" jsonschema:"title=Response Encryption"`
+}
+
+// BackchannelLogoutConfig configures the logout tokens sent to the clients' back-channel logout URIs.
+type BackchannelLogoutConfig struct {
+	OnEndSession bool          `json:"on_end_session" jsonschema:"title=Send on End Session"`
+	SendIDToken  bool          `json:"send_id_token" jsonschema:"title=Send an ID Token Instead"`
+	Token        IDTokenConfig `json:"token" jsonschema:"title=Logout Token"`
+}
+
+// DPoPConfig configures DPoP (RFC 9449) proof validation at the token and userinfo endpoints.
+type DPoPConfig struct {
+	RequireProof  bool `json:"require_proof" jsonschema:"title=Require DPoP Proofs at the Token Endpoint"`
+	RequireNonce  bool `json:"require_nonce" jsonschema:"title=Require Server Nonces"`
+	ProofLifetime int  `json:"proof_lifetime" jsonschema:"title=Proof Lifetime Seconds"`
+	NonceLifetime int  `json:"nonce_lifetime" jsonschema:"title=Nonce Lifetime Seconds"`
+
+	// Misbehaviors.
+	IgnoreBinding bool `json:"ignore_binding" jsonschema:"title=Accept DPoP-Bound Tokens Without a Matching Proof"`
+	IssueBearer   bool `json:"issue_bearer" jsonschema:"title=Issue Bearer Tokens for DPoP Requests"`
+	StaleNonce    bool `json:"stale_nonce" jsonschema:"title=Send Stale Nonces"`
+}
+
+// MTLSConfig configures mutual TLS client authentication and certificate-bound
+// access tokens (RFC 8705).
+type MTLSConfig struct {
+	ClientCAs string `json:"client_cas" jsonschema:"title=Trusted Client CA Certificates (PEM)"`
+
+	// Misbehaviors.
+	IgnoreBinding bool `json:"ignore_binding" jsonschema:"title=Accept Certificate-Bound Tokens Without the Certificate"`
+}
+
+// AuthorizationDetailsConfig configures the authorization_details (RFC 9396)
+// granted in token responses, introspection responses and JWT access tokens.
+type AuthorizationDetailsConfig struct {
+	Grant             string                     `json:"grant" jsonschema:"title=Granted Details,enum=echo,enum=narrow,enum=widen,enum=drop,default=echo"`
+	AdditionalDetails string                     `json:"additional_details" jsonschema:"title=Details Added When Widening (JSON Array)" jsonschema_extras:"hide=grant !== widen"`
+	Rules             []AuthorizationDetailsRule `json:"rules" jsonschema:"title=Per-Type Rewrite Rules"`
+}
+
+// AuthorizationDetailsRule rewrites the granted details of one type.
+type AuthorizationDetailsRule struct {
+	Type   string `json:"type" jsonschema:"title=Details Type"`
+	Action string `json:"action" jsonschema:"title=Rewrite Action,enum=keep,enum=merge,enum=replace,enum=drop,default=keep"`
+	Value  string `json:"value" jsonschema:"title=JSON Object Template"`
+}
+
+// ResourceConfig configures how the resource indicators (RFC 8707) of the
+// authorization and token requests are granted as the token audience.
+type ResourceConfig struct {
+	Grant         string `json:"grant" jsonschema:"title=Granted Resources,enum=requested,enum=ignore,enum=other,enum=all,default=requested"`
+	OtherResource string `json:"other_resource" jsonschema:"title=Other Resource" jsonschema_extras:"hide=grant !== other"`
+}
+
+// JWEConfig configures encryption of a signed JWT to a client key.
+type JWEConfig struct {
+	Encrypt           bool   `json:"encrypt" jsonschema:"title=Encrypt"`
+	JWKS              string `json:"jwks" jsonschema:"title=Client JSON Key Set"`
+	KeyAlgorithm      string `json:"alg" jsonschema:"title=Key Encryption Algorithm,enum=RSA-OAEP,enum=RSA-OAEP-256,enum=ECDH-ES,enum=ECDH-ES+A128KW,enum=ECDH-ES+A256KW,default=RSA-OAEP-256"`
+	ContentEncryption string `json:"enc" jsonschema:"title=Content Encryption Algorithm,enum=A128GCM,enum=A256GCM,enum=A128CBC-HS256,enum=A256CBC-HS512,default=A128GCM"`
+}
+
 // Claim represents an IDToken claim.
 type Claim struct {
-	ID       string   `json:"id" jsonschema:"title=Claim ID"`
-	Values   []string `json:"values" jsonschema:"title=Claim Values"`
-	JSONType string   `json:"json_type" jsonschema:"title=JSON Type,enum=string,enum=array,enum=number,enum=boolean,enum=object,default=string"`
+	ID        string   `json:"id" jsonschema:"title=Claim ID"`
+	Values    []string `json:"values" jsonschema:"title=Claim Values"`
+	CustomKey string   `json:"custom_key" jsonschema:"title=Custom Processor Key"`
+	JSONType  string   `json:"json_type" jsonschema:"title=JSON Type,enum=string,enum=array,enum=number,enum=boolean,enum=object,enum=json,default=string"`
 }
 
 // DiscoveryAction configures the Discovery endpoint.
@@ -109,6 +277,31 @@ type DiscoveryRespond struct {
 	Parameters []Parameter `json:"parameters" jsonschema:"title=Parameters"`
 }
 
+// OAuthMetadataAction configures the OAuth 2.0 Authorization Server Metadata
+// (RFC 8414) endpoint. Mirror responds with the Discovery endpoint parameters.
+type OAuthMetadataAction struct {
+	Action  string           `json:"action_type" jsonschema:"title=Metadata Endpoint Action,enum=respond,enum=mirror,enum=error,enum=block"`
+	Respond DiscoveryRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error            `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+}
+
+// WebFingerAction configures the WebFinger (RFC 7033) issuer discovery endpoint.
+type WebFingerAction struct {
+	Action  string           `json:"action_type" jsonschema:"title=WebFinger Endpoint Action,enum=respond,enum=error,enum=block"`
+	Respond WebFingerRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error            `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+}
+
+// WebFingerRespond configures the WebFinger response. Resources matching an
+// attacker resource get the attacker-chosen issuer.
+type WebFingerRespond struct {
+	Parameters        []Parameter `json:"parameters" jsonschema:"title=Parameters"`
+	AttackerIssuer    string      `json:"attacker_issuer" jsonschema:"title=Attacker-Chosen Issuer"`
+	AttackerResources []string    `json:"attacker_resources" jsonschema:"title=Resources or Hosts Given the Attacker Issuer"`
+}
+
 // UserInfoAction configures the UserInfo endpoint.
 type UserInfoAction struct {
 	Action  string          `json:"action_type" jsonschema:"title=User Info Endpoint Action,enum=respond,enum=error,enum=block"`
@@ -122,6 +315,204 @@ type UserInfoRespond struct {
 	Parameters []Parameter `json:"parameters" jsonschema:"title=Parameters"`
 }
 
+// DeviceAction configures the Device Authorization endpoint.
+type DeviceAction struct {
+	Action  string        `json:"action_type" jsonschema:"title=Device Authorization Endpoint Action,enum=respond,enum=error,enum=block"`
+	Respond DeviceRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error         `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+
+	// Polling configures Token endpoint responses for device_code grants.
+	Polling DevicePolling `json:"polling" jsonschema:"title=Token Polling Config"`
+}
+
+// DeviceRespond configures the Device Authorization endpoint response of JSON content.
+// The device_code and user_code values are tracked for the verification page and
+// Token endpoint.
+type DeviceRespond struct {
+	Parameters []Parameter `json:"parameters" jsonschema:"title=Parameters"`
+}
+
+// DevicePolling configures how the Token endpoint answers device_code polls.
+// The Nth poll returns the Nth schedule entry, after the schedule is exhausted
+// polls follow the tester's decision on the verification page.
+type DevicePolling struct {
+	Schedule        []string `json:"schedule" jsonschema:"title=Poll Response Schedule,enum=authorization_pending,enum=slow_down,enum=expired_token,enum=access_denied,enum=respond"`
+	EnforceInterval bool     `json:"enforce_interval" jsonschema:"title=Return slow_down When Polled Faster Than the Interval"`
+}
+
+// CIBAAction configures the Client-Initiated Backchannel Authentication endpoint.
+type CIBAAction struct {
+	Action  string      `json:"action_type" jsonschema:"title=Backchannel Authentication Endpoint Action,enum=respond,enum=error,enum=block"`
+	Respond CIBARespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error       `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+
+	DeliveryMode string           `json:"delivery_mode" jsonschema:"title=Token Delivery Mode,enum=poll,enum=ping,enum=push,default=poll"`
+	AutoApprove  bool             `json:"auto_approve" jsonschema:"title=Approve Requests Without Tester Interaction"`
+	Notification CIBANotification `json:"notification" jsonschema:"title=Client Notification Config" jsonschema_extras:"hide=delivery_mode === poll"`
+}
+
+// CIBARespond configures the backchannel authentication response of JSON content.
+// The auth_req_id value is tracked for approval and the Token endpoint.
+type CIBARespond struct {
+	Parameters []Parameter `json:"parameters" jsonschema:"title=Parameters"`
+}
+
+// CIBANotification configures the ping or push callback to the client's notification endpoint.
+// Parameters are evaluated with the backchannel authorization as the Session.
+type CIBANotification struct {
+	Endpoint          string      `json:"endpoint" jsonschema:"title=Client Notification Endpoint"`
+	Parameters        []Parameter `json:"parameters" jsonschema:"title=Notification Parameters"`
+	OmitAuthorization bool        `json:"omit_authorization" jsonschema:"title=Omit the Bearer client_notification_token"`
+	UseWrongToken     bool        `json:"use_wrong_token" jsonschema:"title=Send an Incorrect client_notification_token"`
+	MalformedBody     bool        `json:"malformed_body" jsonschema:"title=Send a Malformed Body"`
+}
+
+// PARAction configures the Pushed Authorization Request endpoint.
+type PARAction struct {
+	Action  string     `json:"action_type" jsonschema:"title=Pushed Authorization Request Endpoint Action,enum=respond,enum=error,enum=block"`
+	Respond PARRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error      `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+
+	IgnorePushedParams  bool `json:"ignore_pushed_params" jsonschema:"title=Prefer Query Parameters Over Pushed Parameters"`
+	AllowReuse          bool `json:"allow_reuse" jsonschema:"title=Allow a request_uri to be Used More Than Once"`
+	MalformedRequestURI bool `json:"malformed_request_uri" jsonschema:"title=Return a Malformed request_uri"`
+}
+
+// PARRespond configures the Pushed Authorization Request response of JSON content.
+// The pushed parameters are stored under the returned request_uri for the
+// Authorization endpoint.
+type PARRespond struct {
+	Parameters []Parameter `json:"parameters" jsonschema:"title=Parameters"`
+}
+
+// IntrospectionAction configures the Token Introspection endpoint.
+type IntrospectionAction struct {
+	Action  string               `json:"action_type" jsonschema:"title=Introspection Endpoint Action,enum=respond,enum=error,enum=block"`
+	Respond IntrospectionRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error                `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+}
+
+// IntrospectionRespond configures the introspection response. Parameters are only
+// returned for active tokens, inactive tokens get {"active": false}.
+type IntrospectionRespond struct {
+	Parameters     []Parameter   `json:"parameters" jsonschema:"title=Parameters"`
+	ActiveMode     string        `json:"active_mode" jsonschema:"title=Active Tokens,enum=issued,enum=accept_revoked,enum=accept_any,default=issued"`
+	ResponseFormat string        `json:"response_format" jsonschema:"title=Response Format,enum=requested,enum=json,enum=jwt,default=requested"`
+	JWT            IDTokenConfig `json:"jwt" jsonschema:"title=JWT Response Config" jsonschema_extras:"hide=response_format === json"`
+}
+
+// RevocationAction configures the Token Revocation endpoint.
+type RevocationAction struct {
+	Action string `json:"action_type" jsonschema:"title=Revocation Endpoint Action,enum=respond,enum=error,enum=block"`
+	Error  Error  `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+
+	IgnoreRevocation bool `json:"ignore_revocation" jsonschema:"title=Return Success Without Revoking"`
+}
+
+// LogoutAction configures the RP-Initiated Logout end_session endpoint.
+type LogoutAction struct {
+	Action  string        `json:"action_type" jsonschema:"title=End Session Endpoint Action,enum=respond,enum=error,enum=block"`
+	Respond LogoutRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error         `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+}
+
+// LogoutRespond configures how logout requests are checked and where the user is sent afterwards.
+type LogoutRespond struct {
+	IDTokenHint        string      `json:"id_token_hint" jsonschema:"title=ID Token Hint Handling,enum=validate,enum=ignore,default=validate"`
+	PostLogoutRedirect string      `json:"post_logout_redirect" jsonschema:"title=Post Logout Redirect Handling,enum=validate,enum=allow_any,enum=ignore,default=validate"`
+	Parameters         []Parameter `json:"parameters" jsonschema:"title=Redirect Parameters"`
+	FrontchannelLogout bool        `json:"frontchannel_logout" jsonschema:"title=Render Front-Channel Logout Iframes"`
+}
+
+// RegistrationAction configures the Dynamic Client Registration endpoint.
+type RegistrationAction struct {
+	Action  string              `json:"action_type" jsonschema:"title=Registration Endpoint Action,enum=respond,enum=error,enum=block"`
+	Respond RegistrationRespond `json:"respond" jsonschema:"title=Response Config" jsonschema_extras:"hide=action_type !== respond"`
+	Error   Error               `json:"error" jsonschema:"title=Error Config" jsonschema_extras:"hide=action_type !== error"`
+	// Block doesn't have any parameters.
+}
+
+// RegistrationRespond configures the client information response. The requested
+// client metadata is returned unless a parameter replaces or omits it.
+type RegistrationRespond struct {
+	Parameters        []Parameter             `json:"parameters" jsonschema:"title=Response Parameters"`
+	SoftwareStatement SoftwareStatementConfig `json:"software_statement" jsonschema:"title=Software Statement Config"`
+}
+
+// SoftwareStatementConfig configures how software statements are checked.
+type SoftwareStatementConfig struct {
+	Validation string `json:"validation" jsonschema:"title=Software Statement Validation,enum=ignore,enum=accept,enum=verify,enum=require,default=accept"`
+	JWKS       string `json:"jwks" jsonschema:"title=Software Statement Issuer JSON Key Set"`
+}
+
+// Client is a client registered with the IdP.
+type Client struct {
+	ClientID                    string   `json:"client_id" jsonschema:"title=Client ID"`
+	ClientSecret                string   `json:"client_secret" jsonschema:"title=Client Secret"`
+	RedirectURIs                []string `json:"redirect_uris" jsonschema:"title=Redirect URIs"`
+	JWKS                        string   `json:"jwks" jsonschema:"title=Client JSON Key Set"`
+	TokenEndpointAuthMethod     string   `json:"token_endpoint_auth_method" jsonschema:"title=Token Endpoint Auth Method,enum=client_secret_basic,enum=client_secret_post,enum=client_secret_jwt,enum=private_key_jwt,enum=tls_client_auth,enum=self_signed_tls_client_auth,enum=none,default=client_secret_basic"`
+	TokenEndpointAuthSigningAlg string   `json:"token_endpoint_auth_signing_alg" jsonschema:"title=Client Assertion Signing Algorithm"`
+	TLSClientAuthSubjectDN      string   `json:"tls_client_auth_subject_dn" jsonschema:"title=Client Certificate Subject DN"`
+	IDTokenSignedResponseAlg    string   `json:"id_token_signed_response_alg" jsonschema:"title=ID Token Signing Algorithm"`
+	PostLogoutRedirectURIs      []string `json:"post_logout_redirect_uris" jsonschema:"title=Post Logout Redirect URIs"`
+	FrontchannelLogoutURI       string   `json:"frontchannel_logout_uri" jsonschema:"title=Front-Channel Logout URI"`
+	BackchannelLogoutURI        string   `json:"backchannel_logout_uri" jsonschema:"title=Back-Channel Logout URI"`
+
+	// Misbehaviors skipping client checks.
+	SkipRedirectURICheck bool `json:"skip_redirect_uri_check" jsonschema:"title=Accept Unregistered Redirect URIs"`
+	SkipAuthMethodCheck  bool `json:"skip_auth_method_check" jsonschema:"title=Accept Any Auth Method"`
+	SkipSecretCheck      bool `json:"skip_secret_check" jsonschema:"title=Accept Wrong Client Secrets"`
+	SkipCertificateCheck bool `json:"skip_certificate_check" jsonschema:"title=Accept Any Client Certificate"`
+	SkipSignatureCheck   bool `json:"skip_signature_check" jsonschema:"title=Accept Unverified Client Assertions"`
+	SkipAssertionCheck   bool `json:"skip_assertion_check" jsonschema:"title=Accept Client Assertions With Bad Claims"`
+}
+
+// serverMetadataParameters are the endpoints and capabilities shared by the
+// default Discovery Doc and OAuth 2.0 Authorization Server Metadata.
+var serverMetadataParameters = []Parameter{
+	{ID: "authorization_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/auth"}, JSONType: "string"},
+	{ID: "token_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/token"}, JSONType: "string"},
+	{ID: "jwks_uri", Action: "set", Values: []string{"https://{{.Domain}}/.well-known/jwks.json"}, JSONType: "string"},
+	{ID: "registration_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/register"}, JSONType: "string"},
+	{ID: "device_authorization_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/device_authorization"}, JSONType: "string"},
+	{ID: "backchannel_authentication_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/bc-authorize"}, JSONType: "string"},
+	{ID: "backchannel_token_delivery_modes_supported", Action: "set", JSONType: "array", Values: []string{"poll", "ping", "push"}},
+	{ID: "pushed_authorization_request_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/par"}, JSONType: "string"},
+	{ID: "introspection_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/introspect"}, JSONType: "string"},
+	{ID: "revocation_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/revoke"}, JSONType: "string"},
+	{
+		ID:       "response_modes_supported",
+		Action:   "set",
+		JSONType: "array",
+		Values: []string{
+			"query", "fragment", "form_post", "web_message", "jwt", "query.jwt", "fragment.jwt", "form_post.jwt",
+		},
+	},
+	{
+		ID:       "grant_types_supported",
+		Action:   "set",
+		JSONType: "array",
+		Values: []string{
+			"authorization_code", "implicit", "refresh_token", "client_credentials",
+			"urn:ietf:params:oauth:grant-type:device_code", "urn:ietf:params:oauth:grant-type:token-exchange",
+			"urn:ietf:params:oauth:grant-type:jwt-bearer", "urn:openid:params:grant-type:ciba",
+		},
+	},
+	{ID: "code_challenge_methods_supported", Action: "set", JSONType: "array", Values: []string{"S256", "plain"}},
+	{ID: "token_endpoint_auth_methods_supported", Action: "set", JSONType: "array", Values: []string{"client_secret_basic", "client_secret_post", "client_secret_jwt", "private_key_jwt", "tls_client_auth", "self_signed_tls_client_auth", "none"}},
+	{ID: "token_endpoint_auth_signing_alg_values_supported", Action: "set", JSONType: "array", Values: []string{"HS256", "RS256", "RS512", "ES256"}},
+	{ID: "tls_client_certificate_bound_access_tokens", Action: "set", Values: []string{"true"}, JSONType: "boolean"},
+	{ID: "dpop_signing_alg_values_supported", Action: "set", JSONType: "array", Values: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}},
+	{ID: "authorization_signing_alg_values_supported", Action: "set", JSONType: "array", Values: []string{"RS256", "RS512", "ES256"}},
+}
+
 // DefaultConfig is the config present on first startup. It acts as a default OIDC IDP using
 // authorization code flow and returning a static subject in the ID Token.
 var DefaultConfig = Config{
@@ -133,6 +524,14 @@ var DefaultConfig = Config{
 				{ID: "code", Action: "random", JSONType: "string"},
 				{ID: "redirect_uri", Action: "omit", JSONType: "string"},
 			},
+			ResponseMode: "requested",
+			WebMessage: WebMessage{
+				TargetOrigin: "redirect_uri",
+			},
+		},
+		RequestObject: RequestObjectConfig{
+			AcceptUnsigned: true,
+			Precedence:     "request_object",
 		},
 	},
 	TokenAction: TokenAction{
@@ -144,8 +543,33 @@ var DefaultConfig = Config{
 				{ID: "refresh_token", Action: "random", JSONType: "string"},
 				{ID: "expires_in", Action: "set", JSONType: "number", Values: []string{"3600"}},
 				{ID: "token_type", Action: "set", Values: []string{"Bearer"}, JSONType: "string"},
+				{ID: "authorization_details", Action: "custom", CustomKey: "granted_authorization_details", JSONType: "json"},
+			},
+		},
+		Forward: TokenForward{
+			DefaultParamAction: "passthrough",
+		},
+		GrantTypes: []GrantTypeAction{
+			{
+				GrantType: "urn:ietf:params:oauth:grant-type:token-exchange",
+				Action:    "respond",
+				Respond: TokenRespond{
+					Parameters: []Parameter{
+						{ID: "access_token", Action: "custom", CustomKey: "signed_exchange_token", JSONType: "string"},
+						{ID: "issued_token_type", Action: "set", Values: []string{"urn:ietf:params:oauth:token-type:jwt"}, JSONType: "string"},
+						{ID: "token_type", Action: "set", Values: []string{"Bearer"}, JSONType: "string"},
+						{ID: "expires_in", Action: "set", JSONType: "number", Values: []string{"3600"}},
+					},
+				},
 			},
 		},
+		PKCEMode: "ignore",
+		JWTBearer: JWTBearerConfig{
+			Audience: []string{"https://{{.Domain}}", "https://{{.Domain}}/oauth2/token"},
+		},
+		RefreshToken: RefreshTokenConfig{
+			Mode: "accept_any",
+		},
 	},
 	UserInfoAction: UserInfoAction{
 		Action: "respond",
@@ -159,12 +583,14 @@ var DefaultConfig = Config{
 	DiscoveryAction: DiscoveryAction{
 		Action: "respond",
 		Respond: DiscoveryRespond{
-			Parameters: []Parameter{
+			Parameters: append([]Parameter{
 				{ID: "issuer", Action: "set", Values: []string{"https://{{.Domain}}"}, JSONType: "string"},
-				{ID: "authorization_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/auth"}, JSONType: "string"},
-				{ID: "token_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/token"}, JSONType: "string"},
 				{ID: "userinfo_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/userinfo"}, JSONType: "string"},
-				{ID: "jwks_uri", Action: "set", Values: []string{"https://{{.Domain}}/.well-known/jwks.json"}, JSONType: "string"},
+				{ID: "end_session_endpoint", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/logout"}, JSONType: "string"},
+				{ID: "frontchannel_logout_supported", Action: "set", Values: []string{"true"}, JSONType: "boolean"},
+				{ID: "frontchannel_logout_session_supported", Action: "set", Values: []string{"true"}, JSONType: "boolean"},
+				{ID: "backchannel_logout_supported", Action: "set", Values: []string{"true"}, JSONType: "boolean"},
+				{ID: "backchannel_logout_session_supported", Action: "set", Values: []string{"true"}, JSONType: "boolean"},
 				{ID: "subject_types_supported", Action: "set", JSONType: "array", Values: []string{"public"}},
 				{ID: "id_token_signing_alg_values_supported", Action: "set", JSONType: "array", Values: []string{"RS256", "RS512", "ES256"}},
 				{
@@ -175,6 +601,118 @@ var DefaultConfig = Config{
 						"code", "code id_token", "id_token", "token id_token", "token", "token id_token code",
 					},
 				},
+			}, serverMetadataParameters...),
+		},
+	},
+	OAuthMetadataAction: OAuthMetadataAction{
+		Action: "respond",
+		Respond: DiscoveryRespond{
+			Parameters: append([]Parameter{
+				{ID: "issuer", Action: "set", Values: []string{"https://{{.Domain}}{{.IssuerPath}}"}, JSONType: "string"},
+				{ID: "response_types_supported", Action: "set", JSONType: "array", Values: []string{"code", "token"}},
+			}, serverMetadataParameters...),
+		},
+	},
+	WebFingerAction: WebFingerAction{
+		Action: "respond",
+		Respond: WebFingerRespond{
+			Parameters: []Parameter{
+				{ID: "subject", Action: "custom", CustomKey: "webfinger_subject", JSONType: "string"},
+				{ID: "links", Action: "custom", CustomKey: "webfinger_issuer_links", JSONType: "json"},
+			},
+			AttackerIssuer: "https://attacker.example",
+		},
+	},
+	DeviceAction: DeviceAction{
+		Action: "respond",
+		Respond: DeviceRespond{
+			Parameters: []Parameter{
+				{ID: "device_code", Action: "random", JSONType: "string"},
+				{ID: "user_code", Action: "custom", CustomKey: "device_user_code", JSONType: "string"},
+				{ID: "verification_uri", Action: "set", Values: []string{"https://{{.Domain}}/oauth2/device"}, JSONType: "string"},
+				{ID: "expires_in", Action: "set", Values: []string{"600"}, JSONType: "number"},
+				{ID: "interval", Action: "set", Values: []string{"5"}, JSONType: "number"},
+			},
+		},
+	},
+	CIBAAction: CIBAAction{
+		Action: "respond",
+		Respond: CIBARespond{
+			Parameters: []Parameter{
+				{ID: "auth_req_id", Action: "random", JSONType: "string"},
+				{ID: "expires_in", Action: "set", Values: []string{"120"}, JSONType: "number"},
+				{ID: "interval", Action: "set", Values: []string{"5"}, JSONType: "number"},
+			},
+		},
+		DeliveryMode: "poll",
+		Notification: CIBANotification{
+			Parameters: []Parameter{
+				{ID: "auth_req_id", Action: "set", Values: []string{"{{.Session.Code}}"}, JSONType: "string"},
+			},
+		},
+	},
+	PARAction: PARAction{
+		Action: "respond",
+		Respond: PARRespond{
+			Parameters: []Parameter{
+				{ID: "request_uri", Action: "custom", CustomKey: "par_request_uri", JSONType: "string"},
+				{ID: "expires_in", Action: "set", Values: []string{"60"}, JSONType: "number"},
+			},
+		},
+	},
+	IntrospectionAction: IntrospectionAction{
+		Action: "respond",
+		Respond: IntrospectionRespond{
+			Parameters: []Parameter{
+				{ID: "scope", Action: "set", Values: []string{"{{with .Token}}{{.Scope}}{{end}}"}, JSONType: "string"},
+				{ID: "client_id", Action: "set", Values: []string{"{{with .Token}}{{.ClientID}}{{end}}"}, JSONType: "string"},
+				{ID: "sub", Action: "set", Values: []string{"12345abcde"}, JSONType: "string"},
+				{ID: "token_type", Action: "set", Values: []string{"{{with .Token}}{{if eq .Type \"access_token\"}}{{if .JKT}}DPoP{{else}}Bearer{{end}}{{end}}{{end}}"}, JSONType: "string"},
+				{ID: "cnf", Action: "set", Values: []string{"{{with .Token}}{{if .JKT}}{\"jkt\":\"{{.JKT}}\"}{{else if .X5T}}{\"x5t#S256\":\"{{.X5T}}\"}{{end}}{{end}}"}, JSONType: "object"},
+				{ID: "iss", Action: "set", Values: []string{"https://{{.Domain}}"}, JSONType: "string"},
+				{ID: "iat", Action: "set", Values: []string{"{{with .Token}}{{.IssuedAt.Unix}}{{end}}"}, JSONType: "number"},
+				{ID: "exp", Action: "set", Values: []string{"{{with .Token}}{{if not .ExpiresAt.IsZero}}{{.ExpiresAt.Unix}}{{end}}{{end}}"}, JSONType: "number"},
+				{ID: "authorization_details", Action: "custom", CustomKey: "granted_authorization_details", JSONType: "json"},
+			},
+			ActiveMode:     "issued",
+			ResponseFormat: "requested",
+			JWT: IDTokenConfig{
+				Algorithm: "RS256",
+				Type:      "token-introspection+jwt",
+				Claims: []Claim{
+					{ID: "iss", Values: []string{"https://{{.Domain}}"}, JSONType: "string"},
+					{ID: "aud", Values: []string{"{{.FormParams.Get \"client_id\"}}"}, JSONType: "string"},
+					{ID: "iat", JSONType: "number", Values: []string{"{{.Time.Unix}}"}},
+				},
+			},
+		},
+	},
+	RevocationAction: RevocationAction{
+		Action: "respond",
+	},
+	LogoutAction: LogoutAction{
+		Action: "respond",
+		Respond: LogoutRespond{
+			IDTokenHint:        "validate",
+			PostLogoutRedirect: "validate",
+			Parameters: []Parameter{
+				{ID: "state", Action: "passthrough", JSONType: "string"},
+			},
+			FrontchannelLogout: true,
+		},
+	},
+	RegistrationAction: RegistrationAction{
+		Action: "respond",
+		Respond: RegistrationRespond{
+			Parameters: []Parameter{
+				{ID: "client_id", Action: "random", JSONType: "string"},
+				{ID: "client_secret", Action: "random", JSONType: "string"},
+				{ID: "registration_access_token", Action: "random", JSONType: "string"},
+				{ID: "client_id_issued_at", Action: "set", Values: []string{"{{.Time.Unix}}"}, JSONType: "number"},
+				{ID: "client_secret_expires_at", Action: "set", Values: []string{"0"}, JSONType: "number"},
+			},
+			SoftwareStatement: SoftwareStatementConfig{
+				Validation: "accept",
 			},
 		},
 	},
@@ -187,10 +725,79 @@ var DefaultConfig = Config{
 			{ID: "iat", JSONType: "number", Values: []string{"{{.Time.Unix}}"}},
 			{ID: "exp", JSONType: "number", Values: []string{"{{with $tomorrow := .Time.AddDate 0 0 1}}{{$tomorrow.Unix}}{{end}}"}},
 			{ID: "sub", Values: []string{"12345abcde"}, JSONType: "string"},
+			{ID: "sid", Values: []string{"{{if .Session}}{{.Session.SessionID}}{{end}}"}, JSONType: "string"},
 		},
 		RemoveSignature: false,
 		UseWrongKey:     false,
 	},
+	ExchangeTokenConfig: IDTokenConfig{
+		Algorithm: "RS256",
+		Claims: []Claim{
+			{ID: "iss", Values: []string{"https://{{.Domain}}"}, JSONType: "string"},
+			{ID: "sub", Values: []string{"{{with .TokenExchange}}{{index .SubjectClaims \"sub\"}}{{end}}"}, JSONType: "string"},
+			{ID: "aud", Values: []string{"{{with .TokenExchange}}{{with .Audience}}{{index . 0}}{{end}}{{end}}"}, JSONType: "string"},
+			{ID: "act", CustomKey: "exchange_actor", JSONType: "object"},
+			{ID: "iat", JSONType: "number", Values: []string{"{{.Time.Unix}}"}},
+			{ID: "exp", JSONType: "number", Values: []string{"{{with $tomorrow := .Time.AddDate 0 0 1}}{{$tomorrow.Unix}}{{end}}"}},
+		},
+	},
+	AccessTokenConfig: IDTokenConfig{
+		Algorithm: "RS256",
+		Type:      "at+jwt",
+		Claims: []Claim{
+			{ID: "iss", Values: []string{"https://{{.Domain}}"}, JSONType: "string"},
+			{ID: "sub", Values: []string{"12345abcde"}, JSONType: "string"},
+			{ID: "aud", CustomKey: "resource_audience", JSONType: "array"},
+			{ID: "client_id", Values: []string{"{{if and .Session .Session.ClientID}}{{.Session.ClientID}}{{else}}{{.ClientID}}{{end}}"}, JSONType: "string"},
+			{ID: "scope", Values: []string{"{{if and .Session .Session.Scope}}{{.Session.Scope}}{{else}}{{.FormParams.Get \"scope\"}}{{end}}"}, JSONType: "string"},
+			{ID: "iat", JSONType: "number", Values: []string{"{{.Time.Unix}}"}},
+			{ID: "exp", JSONType: "number", Values: []string{"{{.ExpiresIn 3600}}"}},
+			{ID: "cnf", CustomKey: "token_confirmation", JSONType: "object"},
+			{ID: "authorization_details", CustomKey: "granted_authorization_details", JSONType: "json"},
+		},
+	},
+	ResourceConfig: ResourceConfig{
+		Grant:         "requested",
+		OtherResource: "https://attacker.example/api",
+	},
+	AuthorizationDetailsConfig: AuthorizationDetailsConfig{
+		Grant:             "echo",
+		AdditionalDetails: `[{"type":"account_information","actions":["list_accounts","read_balances","read_transactions"]}]`,
+	},
+	JARMConfig: JARMConfig{
+		Token: IDTokenConfig{
+			Algorithm: "RS256",
+			Claims: []Claim{
+				{ID: "iss", Values: []string{"https://{{.Domain}}"}, JSONType: "string"},
+				{ID: "aud", Values: []string{"{{.URLParams.Get \"client_id\"}}"}, JSONType: "string"},
+				{ID: "exp", JSONType: "number", Values: []string{"{{.ExpiresIn 600}}"}},
+			},
+		},
+		Encryption: JWEConfig{
+			KeyAlgorithm:      "RSA-OAEP-256",
+			ContentEncryption: "A128GCM",
+		},
+	},
+	BackchannelLogoutConfig: BackchannelLogoutConfig{
+		OnEndSession: true,
+		Token: IDTokenConfig{
+			Algorithm: "RS256",
+			Type:      "logout+jwt",
+			Claims: []Claim{
+				{ID: "iss", Values: []string{"https://{{.Domain}}"}, JSONType: "string"},
+				{ID: "aud", Values: []string{"{{.Session.ClientID}}"}, JSONType: "string"},
+				{ID: "iat", JSONType: "number", Values: []string{"{{.Time.Unix}}"}},
+				{ID: "exp", JSONType: "number", Values: []string{"{{.ExpiresIn 120}}"}},
+				{ID: "sub", Values: []string{"12345abcde"}, JSONType: "string"},
+				{ID: "sid", Values: []string{"{{.Session.SessionID}}"}, JSONType: "string"},
+				{ID: "events", Values: []string{"{\"http://schemas.openid.net/event/backchannel-logout\":{}}"}, JSONType: "object"},
+			},
+		},
+	},
+	DPoPConfig: DPoPConfig{
+		ProofLifetime: 300,
+		NonceLifetime: 300,
+	},
 }
 
 // Config storage.
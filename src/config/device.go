@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+)
+
+// userCodeCharset is the RFC 8628 Section 6.1 recommended user code character set.
+const userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+
+func init() {
+	RegisterCustomParam("device_user_code", GenerateUserCode)
+}
+
+// GenerateUserCode creates a short device flow user code such as WDJB-MJHT.
+func GenerateUserCode(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	b := make([]byte, 8)
+	if _, err := randMethod(b); err != nil {
+		return nil, err
+	}
+
+	code := make([]byte, 0, 9)
+	for i, c := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, userCodeCharset[int(c)%len(userCodeCharset)])
+	}
+	return []string{string(code)}, nil
+}
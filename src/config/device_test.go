@@ -0,0 +1,32 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"customidp/session"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateUserCode(t *testing.T) {
+	got, err := GenerateUserCode(&session.RequestInput{}, &Config{})
+	if err != nil {
+		t.Fatalf("GenerateUserCode() failed: %v", err)
+	}
+
+	if len(got) != 1 || !regexp.MustCompile(`^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`).MatchString(got[0]) {
+		t.Errorf("GenerateUserCode() returned unexpected user code %v", got)
+	}
+}
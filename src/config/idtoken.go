@@ -17,6 +17,7 @@ package config
 import (
 	"customidp/keys"
 	sessionmgmt "customidp/session"
+	"encoding/json"
 	"strings"
 
 	"github.com/lestrrat-go/jwx/jwt"
@@ -24,22 +25,113 @@ import (
 
 func init() {
 	RegisterCustomParam("signed_token_id", GenerateToken)
+	RegisterCustomParam("signed_exchange_token", GenerateExchangeToken)
+	RegisterCustomParam("exchange_actor", ExchangeActor)
+	RegisterCustomParam("signed_access_token", GenerateAccessToken)
+	RegisterCustomParam("resource_audience", ResourceAudience)
+	RegisterCustomParam("token_confirmation", TokenConfirmation)
 }
 
-// GenerateToken creates a JWT token based on the IDTokenConfig.
+// GenerateToken creates a JWT token based on the IDTokenConfig. The signing
+// algorithm of a registered client's id_token_signed_response_alg is used.
 func GenerateToken(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
-	token := jwt.New()
-	for _, claim := range config.IDTokenConfig.Claims {
+	c := config.IDTokenConfig
+	clientID := input.ClientID
+	if clientID == "" && input.Session != nil {
+		clientID = input.Session.ClientID
+	}
+	if client, ok := config.GetClient(clientID); ok && client.IDTokenSignedResponseAlg != "" {
+		c.Algorithm = client.IDTokenSignedResponseAlg
+	}
+	return generateJWT(input, &c)
+}
+
+// GenerateExchangeToken creates a JWT token based on the ExchangeTokenConfig.
+func GenerateExchangeToken(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	return generateJWT(input, &config.ExchangeTokenConfig)
+}
+
+// ExchangeActor returns the act claim of a Token Exchange delegation: the actor
+// token's sub, with the subject token's act nested as the prior actors. Nothing is
+// returned without an actor token.
+func ExchangeActor(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	exchange := input.TokenExchange
+	if exchange == nil || exchange.ActorClaims == nil {
+		return nil, nil
+	}
+
+	act := map[string]any{"sub": exchange.ActorClaims["sub"]}
+	if prior, ok := exchange.SubjectClaims["act"]; ok {
+		act["act"] = prior
+	}
+	b, err := json.Marshal(act)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(b)}, nil
+}
+
+// GenerateAccessToken creates a JWT access token based on the AccessTokenConfig.
+func GenerateAccessToken(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	return generateJWT(input, &config.AccessTokenConfig)
+}
+
+// ResourceAudience returns the granted resources as the token audience, or the
+// UserInfo endpoint if no resources are granted.
+func ResourceAudience(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	if len(input.Resources) == 0 {
+		return []string{"https://" + input.Domain + "/oauth2/userinfo"}, nil
+	}
+	return input.Resources, nil
+}
+
+// TokenConfirmation returns the cnf claim binding an access token to the DPoP
+// proof key (jkt) or the client certificate (x5t#S256) of the request. Nothing is
+// returned for unbound tokens, including DPoP requests issued Bearer tokens.
+func TokenConfirmation(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	cnf := map[string]string{}
+	if input.DPoP != nil && !config.DPoPConfig.IssueBearer {
+		cnf["jkt"] = input.DPoP.JKT
+	} else if input.CertificateThumbprint != "" {
+		cnf["x5t#S256"] = input.CertificateThumbprint
+	} else {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(cnf)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(b)}, nil
+}
+
+// generateJWT creates and signs a JWT with claims evaluated against the input.
+func generateJWT(input *sessionmgmt.RequestInput, c *IDTokenConfig) ([]string, error) {
+	signed, err := signJWT(input, c, jwt.New())
+	if err != nil {
+		return nil, err
+	}
+	return []string{signed}, nil
+}
+
+// signJWT adds the configured claims evaluated against the input to the token and signs it.
+// Claims with a custom processor key take their value from the processor.
+func signJWT(input *sessionmgmt.RequestInput, c *IDTokenConfig, token jwt.Token) (string, error) {
+	for _, claim := range c.Claims {
 		p := Parameter{
 			ID:       claim.ID,
 			Action:   "set",
 			Values:   claim.Values,
 			JSONType: claim.JSONType,
 		}
+		if claim.CustomKey != "" {
+			p.Action = "custom"
+			p.CustomKey = claim.CustomKey
+		}
 
 		jsonVal, err := p.GetJSON(input)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 
 		if jsonVal != nil {
@@ -47,17 +139,17 @@ func GenerateToken(input *sessionmgmt.RequestInput, config *Config) ([]string, e
 		}
 	}
 
-	signed, err := keys.SignToken(config.IDTokenConfig.Algorithm, token, config.IDTokenConfig.UseWrongKey)
+	signed, err := keys.SignTokenWithType(c.Algorithm, token, c.UseWrongKey, c.Type)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	if config.IDTokenConfig.RemoveSignature {
+	if c.RemoveSignature {
 		i := strings.LastIndex(signed, ".")
 		if i >= 0 && i < len(signed)-1 {
 			signed = signed[:i+1]
 		}
 	}
 
-	return []string{signed}, nil
+	return signed, nil
 }
@@ -17,6 +17,7 @@ package config
 import (
 	"customidp/keys"
 	"customidp/session"
+	"reflect"
 	"testing"
 
 	"github.com/lestrrat-go/jwx/jwa"
@@ -66,3 +67,41 @@ func TestGenerateToken(t *testing.T) {
 		t.Errorf("GenerateToken() created unparsable token: %v", err)
 	}
 }
+
+func TestTokenConfirmation(t *testing.T) {
+	cases := []struct {
+		title string
+		input session.RequestInput
+		dpop  DPoPConfig
+		want  []string
+	}{
+		{title: "Unbound"},
+		{
+			title: "DPoP",
+			input: session.RequestInput{DPoP: &session.DPoPProof{JKT: "jkt123"}, CertificateThumbprint: "x5t123"},
+			want:  []string{`{"jkt":"jkt123"}`},
+		},
+		{
+			title: "DPoP issued Bearer",
+			input: session.RequestInput{DPoP: &session.DPoPProof{JKT: "jkt123"}},
+			dpop:  DPoPConfig{IssueBearer: true},
+		},
+		{
+			title: "Client certificate",
+			input: session.RequestInput{CertificateThumbprint: "x5t123"},
+			want:  []string{`{"x5t#S256":"x5t123"}`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := TokenConfirmation(&tc.input, &Config{DPoPConfig: tc.dpop})
+			if err != nil {
+				t.Fatalf("TokenConfirmation() failed with unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("TokenConfirmation() returned %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// GenerateIntrospectionToken creates a JWT introspection response (RFC 9701)
+// holding the introspection response content in the token_introspection claim.
+func GenerateIntrospectionToken(input *sessionmgmt.RequestInput, config *Config, content map[string]any) (string, error) {
+	token := jwt.New()
+	token.Set("token_introspection", content)
+	return signJWT(input, &config.IntrospectionAction.Respond.JWT, token)
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"net/url"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// GenerateResponseToken creates a JARM response token holding the authorization
+// response parameters. Configured claims take precedence over the parameters.
+func GenerateResponseToken(input *sessionmgmt.RequestInput, config *Config, params url.Values) (string, error) {
+	token := jwt.New()
+	for id := range params {
+		token.Set(id, params.Get(id))
+	}
+
+	signed, err := signJWT(input, &config.JARMConfig.Token, token)
+	if err != nil {
+		return "", err
+	}
+
+	e := config.JARMConfig.Encryption
+	if !e.Encrypt {
+		return signed, nil
+	}
+	return keys.EncryptToken(signed, e.JWKS, e.KeyAlgorithm, e.ContentEncryption)
+}
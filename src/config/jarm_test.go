@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"customidp/keys"
+	"customidp/session"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+func TestGenerateResponseToken(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	input := &session.RequestInput{
+		Domain:    "test.com",
+		URLParams: url.Values{"client_id": {"jarmclient"}},
+		Time:      now,
+	}
+	params := url.Values{"code": {"authcode"}, "state": {"jarmstate"}}
+
+	got, err := GenerateResponseToken(input, &DefaultConfig, params)
+	if err != nil {
+		t.Fatalf("GenerateResponseToken() failed: %v", err)
+	}
+
+	pubKey, err := keys.GetKey("RSA", false).Jwk.PublicKey()
+	if err != nil {
+		t.Fatalf("Failed to get RSA key: %v", err)
+	}
+
+	token, err := jwt.ParseString(got, jwt.WithVerify(jwa.RS256, pubKey))
+	if err != nil {
+		t.Fatalf("GenerateResponseToken() created unparsable token: %v", err)
+	}
+
+	if token.Issuer() != "https://test.com" {
+		t.Errorf("GenerateResponseToken() returned iss %q", token.Issuer())
+	}
+	if aud := token.Audience(); len(aud) != 1 || aud[0] != "jarmclient" {
+		t.Errorf("GenerateResponseToken() returned aud %v", aud)
+	}
+	if token.Expiration().Unix() != now.Add(10*time.Minute).Unix() {
+		t.Errorf("GenerateResponseToken() returned exp %v", token.Expiration())
+	}
+	for id := range params {
+		if v, _ := token.Get(id); v != params.Get(id) {
+			t.Errorf("GenerateResponseToken() returned %s %v, expected %q", id, v, params.Get(id))
+		}
+	}
+}
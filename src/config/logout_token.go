@@ -0,0 +1,40 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"encoding/base64"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// GenerateLogoutToken creates a back-channel logout token for the client and
+// session ID in the input session. A random jti is set unless a claim replaces it.
+func GenerateLogoutToken(input *sessionmgmt.RequestInput, config *Config) (string, error) {
+	c := &config.BackchannelLogoutConfig
+	if c.SendIDToken {
+		return signJWT(input, &config.IDTokenConfig, jwt.New())
+	}
+
+	b := make([]byte, 16)
+	if _, err := randMethod(b); err != nil {
+		return "", err
+	}
+
+	token := jwt.New()
+	token.Set("jti", base64.RawURLEncoding.EncodeToString(b))
+	return signJWT(input, &c.Token, token)
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"customidp/keys"
+	"customidp/session"
+	"testing"
+	"time"
+)
+
+func TestGenerateLogoutToken(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	input := &session.RequestInput{
+		Domain:  "test.com",
+		Session: &session.Session{ClientID: "rp", SessionID: "sid123"},
+		Time:    time.Now(),
+	}
+
+	idToken := DefaultConfig
+	idToken.BackchannelLogoutConfig.SendIDToken = true
+
+	noEvent := DefaultConfig
+	noEvent.BackchannelLogoutConfig.Token.Claims = DefaultConfig.BackchannelLogoutConfig.Token.Claims[:6]
+
+	cases := []struct {
+		title     string
+		config    *Config
+		wantTyp   any
+		wantEvent bool
+		wantJTI   bool
+	}{
+		{title: "Logout token", config: &DefaultConfig, wantTyp: "logout+jwt", wantEvent: true, wantJTI: true},
+		{title: "Event missing", config: &noEvent, wantTyp: "logout+jwt", wantJTI: true},
+		{title: "ID token sent", config: &idToken, wantTyp: "JWT"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := GenerateLogoutToken(input, tc.config)
+			if err != nil {
+				t.Fatalf("GenerateLogoutToken() failed: %v", err)
+			}
+
+			header, claims, err := keys.DecodeToken(got)
+			if err != nil {
+				t.Fatalf("GenerateLogoutToken() created unparsable token: %v", err)
+			}
+
+			if header["typ"] != tc.wantTyp {
+				t.Errorf("GenerateLogoutToken() returned typ %v, expected %v", header["typ"], tc.wantTyp)
+			}
+			if claims["sid"] != "sid123" {
+				t.Errorf("GenerateLogoutToken() returned sid %v", claims["sid"])
+			}
+			if _, ok := claims["nonce"]; ok {
+				t.Errorf("GenerateLogoutToken() returned a nonce")
+			}
+
+			events, _ := claims["events"].(map[string]any)
+			if _, ok := events["http://schemas.openid.net/event/backchannel-logout"]; ok != tc.wantEvent {
+				t.Errorf("GenerateLogoutToken() returned events %v", claims["events"])
+			}
+			if jti, _ := claims["jti"].(string); (jti != "") != tc.wantJTI {
+				t.Errorf("GenerateLogoutToken() returned jti %q", jti)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"encoding/base64"
+)
+
+// RequestURIPrefix is the RFC 9126 prefix of pushed authorization request_uri values.
+const RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+func init() {
+	RegisterCustomParam("par_request_uri", GenerateRequestURI)
+}
+
+// GenerateRequestURI creates a random request_uri for a pushed authorization request.
+func GenerateRequestURI(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	b := make([]byte, 32)
+	if _, err := randMethod(b); err != nil {
+		return nil, err
+	}
+	return []string{RequestURIPrefix + base64.RawURLEncoding.EncodeToString(b)}, nil
+}
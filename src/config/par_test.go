@@ -0,0 +1,32 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"customidp/session"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateRequestURI(t *testing.T) {
+	got, err := GenerateRequestURI(&session.RequestInput{}, &Config{})
+	if err != nil {
+		t.Fatalf("GenerateRequestURI() failed: %v", err)
+	}
+
+	if len(got) != 1 || !regexp.MustCompile(`^urn:ietf:params:oauth:request_uri:[A-Za-z0-9_-]{43}$`).MatchString(got[0]) {
+		t.Errorf("GenerateRequestURI() returned unexpected request_uri %v", got)
+	}
+}
@@ -94,6 +94,12 @@ func getJSONType(vals []string, jsonType string) (any, error) {
 			return nil, fmt.Errorf("failed to parse %q as a JSON Object %v", vals[0], err)
 		}
 		return obj, nil
+	case "json":
+		var val any
+		if err := json.Unmarshal([]byte(vals[0]), &val); err != nil {
+			return nil, fmt.Errorf("failed to parse %q as JSON %v", vals[0], err)
+		}
+		return val, nil
 	}
 
 	// Default to string type.
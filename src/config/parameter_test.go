@@ -214,6 +214,25 @@ func TestGetJSON(t *testing.T) {
 			},
 			err: fmt.Errorf(""),
 		},
+		{
+			param: Parameter{
+				ID:       "test_json",
+				Action:   "set",
+				Values:   []string{`[{"type": "payment_initiation"}]`},
+				JSONType: "json",
+			},
+			want: []any{map[string]any{"type": "payment_initiation"}},
+			err:  nil,
+		},
+		{
+			param: Parameter{
+				ID:       "test_json_invalid",
+				Action:   "set",
+				Values:   []string{`[{"type"`},
+				JSONType: "json",
+			},
+			err: fmt.Errorf(""),
+		},
 		{
 			param: Parameter{
 				ID:       "test_empty",
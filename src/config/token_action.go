@@ -0,0 +1,30 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// ForGrantType returns the TokenAction with the action for grantType applied.
+// The TokenAction itself is the default when no grant type specific action matches.
+func (a TokenAction) ForGrantType(grantType string) TokenAction {
+	for _, g := range a.GrantTypes {
+		if g.GrantType == grantType {
+			a.Action = g.Action
+			a.Respond = g.Respond
+			a.Forward = g.Forward
+			a.Error = g.Error
+			break
+		}
+	}
+	return a
+}
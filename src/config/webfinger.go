@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// WebFingerIssuerRel is the WebFinger link relation of OpenID Connect issuers.
+const WebFingerIssuerRel = "http://openid.net/specs/connect/1.0/issuer"
+
+func init() {
+	RegisterCustomParam("webfinger_subject", WebFingerSubject)
+	RegisterCustomParam("webfinger_issuer_links", WebFingerIssuerLinks)
+}
+
+// WebFingerSubject returns the resource of a WebFinger request as the subject.
+func WebFingerSubject(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	resource := input.URLParams.Get("resource")
+	if resource == "" {
+		return nil, nil
+	}
+	return []string{resource}, nil
+}
+
+// WebFingerIssuerLinks returns the issuer link of a WebFinger request as a JSON
+// array. It is empty if the request asks for other link relations only.
+func WebFingerIssuerLinks(input *sessionmgmt.RequestInput, config *Config) ([]string, error) {
+	links := []map[string]string{}
+	if rels := input.URLParams["rel"]; len(rels) == 0 || slices.Contains(rels, WebFingerIssuerRel) {
+		links = append(links, map[string]string{"rel": WebFingerIssuerRel, "href": webFingerIssuer(input, &config.WebFingerAction.Respond)})
+	}
+
+	b, err := json.Marshal(links)
+	if err != nil {
+		return nil, err
+	}
+	return []string{string(b)}, nil
+}
+
+// webFingerIssuer returns the IdP issuer, or the attacker-chosen issuer if the
+// resource or its host is one of the attacker resources.
+func webFingerIssuer(input *sessionmgmt.RequestInput, c *WebFingerRespond) string {
+	resource := input.URLParams.Get("resource")
+	if c.AttackerIssuer != "" && resource != "" {
+		if slices.Contains(c.AttackerResources, resource) || slices.Contains(c.AttackerResources, webFingerHost(resource)) {
+			return c.AttackerIssuer
+		}
+	}
+	return "https://" + input.Domain
+}
+
+// webFingerHost returns the host of an acct: or URL resource.
+func webFingerHost(resource string) string {
+	if acct, ok := strings.CutPrefix(resource, "acct:"); ok {
+		if i := strings.LastIndex(acct, "@"); i >= 0 {
+			return acct[i+1:]
+		}
+		return ""
+	}
+
+	u, err := url.Parse(resource)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	sessionmgmt "customidp/session"
+	"net/url"
+	"testing"
+)
+
+func TestWebFingerIssuer(t *testing.T) {
+	c := WebFingerRespond{
+		AttackerIssuer:    "https://attacker.example",
+		AttackerResources: []string{"victim.example", "acct:admin@idp.idp"},
+	}
+
+	cases := []struct {
+		resource string
+		want     string
+	}{
+		{resource: "acct:alice@idp.idp", want: "https://idp.idp"},
+		{resource: "https://idp.idp/alice", want: "https://idp.idp"},
+		{resource: "acct:admin@idp.idp", want: "https://attacker.example"},
+		{resource: "acct:alice@victim.example", want: "https://attacker.example"},
+		{resource: "https://victim.example:8443/alice", want: "https://attacker.example"},
+		{resource: "acct:victim.example", want: "https://idp.idp"},
+	}
+
+	for _, tc := range cases {
+		input := &sessionmgmt.RequestInput{Domain: "idp.idp", URLParams: url.Values{"resource": {tc.resource}}}
+		if got := webFingerIssuer(input, &c); got != tc.want {
+			t.Errorf("webFingerIssuer() for %q returned %q, expected %q", tc.resource, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// approvalTemplate is the page where the tester approves or denies a pending request.
+var approvalTemplate = template.Must(template.New("approval").Parse(`<html>
+<head><title>{{.Title}}</title><link rel='stylesheet' href='/log.css'></head>
+<body>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+<form method='POST' action='{{.Action}}'>
+<label for='{{.Field}}'>{{.Label}}</label>
+<input id='{{.Field}}' name='{{.Field}}' value='{{.Value}}'>
+{{if .Submit}}<button type='submit' name='decision' value='submit'>{{.Submit}}</button>
+{{else}}<button type='submit' name='decision' value='approve'>Approve</button>
+<button type='submit' name='decision' value='deny'>Deny</button>
+{{end}}</form>
+</body>
+</html>`))
+
+// approvalPage is the content of an approval page.
+type approvalPage struct {
+	// Page title.
+	Title string
+
+	// Path the form posts to.
+	Action string
+
+	// Name of the form field identifying the request.
+	Field string
+
+	// Label of the form field.
+	Label string
+
+	// Prefilled form field value.
+	Value string
+
+	// Result of a previous decision if any.
+	Message string
+
+	// Label of a single submit button replacing the approve and deny buttons.
+	Submit string
+}
+
+// writeApprovalPage renders an approval page. Only same origin form posts are allowed.
+func writeApprovalPage(w http.ResponseWriter, page *approvalPage) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'self'; form-action 'self'")
+	approvalTemplate.Execute(w, page)
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // authHandler takes in a oauth2 auth request and redirects a target with either passed-through or replaced parameters.
@@ -42,7 +43,35 @@ func authHandler(w http.ResponseWriter, r *http.Request) {
 
 // authRedirect creates a http redirect based on configuration.
 func authRedirect(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput) {
-	redirect := config.GetGlobalConfig().AuthAction.Redirect
+	c := config.GetGlobalConfig()
+	redirect := c.AuthAction.Redirect
+
+	// Load the parameters of a pushed authorization request.
+	if err := resolvePushedRequest(&c.PARAction, input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request_uri %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Merge in the claims of a request object.
+	if err := resolveRequestObject(&c.AuthAction.RequestObject, input); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request object %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Reject malformed Rich Authorization Request details.
+	if _, err := sessionmgmt.ParseAuthorizationDetails(input.URLParams.Get("authorization_details")); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid authorization_details %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Grant the resource indicators as the audience of tokens issued here.
+	resources, err := grantResources(&c.ResourceConfig, nil, input.URLParams["resource"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid resource %v", err), http.StatusBadRequest)
+		return
+	}
+	input.Resources = resources
+
 	paramsMap := make(map[string]config.Parameter)
 	for _, param := range redirect.Parameters {
 		paramsMap[param.ID] = param
@@ -62,21 +91,91 @@ func authRedirect(w http.ResponseWriter, r *http.Request, input *sessionmgmt.Req
 		return
 	}
 
+	// Registered clients can only use their redirect URIs.
+	if client, ok := c.GetClient(input.URLParams.Get("client_id")); ok && !redirect.RedirectTarget.UseCustomRedirectURI && !client.AllowsRedirect(redirectURI) {
+		http.Error(w, "Invalid redirect_uri not registered for the client", http.StatusBadRequest)
+		return
+	}
+
+	if err := sessionmgmt.CreateSession(input, redirectParams); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Wrap the response parameters in a JARM response token.
+	if getJARMDelivery(input) != "" {
+		token, err := config.GenerateResponseToken(input, c, redirectParams)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		redirectParams = url.Values{"response": {token}}
+	}
+
+	delivery := getResponseDelivery(input, &redirect)
+
+	switch delivery {
+	case "form_post":
+		writeFormPost(w, redirectURI, redirectParams)
+		return
+	case "web_message":
+		writeWebMessage(w, getTargetOrigin(redirectURI, &redirect.WebMessage), redirectParams)
+		return
+	}
+
 	reqURI := redirectURI
-	if redirect.UseHashFragment {
+	if delivery == "fragment" {
 		reqURI += "#" + redirectParams.Encode()
 	} else {
 		reqURI += "?" + redirectParams.Encode()
 	}
 
-	sessionmgmt.CreateSession(input, redirectParams)
-
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Pragma", "no-cache")
 
 	http.Redirect(w, r, reqURI, http.StatusFound)
 }
 
+// getResponseDelivery returns how the authorization response is delivered, one of
+// query, fragment, form_post or web_message. A configured response mode overrides the
+// one requested.
+func getResponseDelivery(input *sessionmgmt.RequestInput, c *config.AuthRedirect) string {
+	switch c.ResponseMode {
+	case "query", "fragment", "form_post", "web_message":
+		return c.ResponseMode
+	}
+
+	if jarmDelivery := getJARMDelivery(input); jarmDelivery != "" {
+		return jarmDelivery
+	}
+
+	if c.UseHashFragment {
+		return "fragment"
+	}
+
+	switch mode := input.URLParams.Get("response_mode"); mode {
+	case "fragment", "form_post", "web_message":
+		return mode
+	}
+	return "query"
+}
+
+// getJARMDelivery returns how a JARM response_mode delivers the response token,
+// one of query, fragment or form_post. It is empty for other response modes.
+func getJARMDelivery(input *sessionmgmt.RequestInput) string {
+	switch mode := input.URLParams.Get("response_mode"); mode {
+	case "query.jwt", "fragment.jwt", "form_post.jwt":
+		return strings.TrimSuffix(mode, ".jwt")
+	case "jwt":
+		// The default for response types issuing tokens is fragment.jwt.
+		if strings.Contains(input.URLParams.Get("response_type"), "token") {
+			return "fragment"
+		}
+		return "query"
+	}
+	return ""
+}
+
 // getRedirectURI gets a set or custom redirectURI if specified, otherwise
 // uses the input parameter.
 func getRedirectURI(input *sessionmgmt.RequestInput, c *config.AuthRedirect) (string, error) {
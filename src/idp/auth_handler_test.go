@@ -16,13 +16,18 @@ package idp
 
 import (
 	"customidp/config"
+	"customidp/keys"
 	"customidp/session"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
 )
 
 func TestAuthHandler(t *testing.T) {
@@ -257,3 +262,173 @@ func TestAuthHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandlerJARM(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, err := keys.GetKey("RSA", false).Jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		title        string
+		responseMode string
+		responseType string
+		useWrongKey  bool
+		wantDelivery string
+		wantVerified bool
+	}{
+		{title: "jwt for code", responseMode: "jwt", responseType: "code", wantDelivery: "query", wantVerified: true},
+		{title: "jwt for token", responseMode: "jwt", responseType: "code id_token", wantDelivery: "fragment", wantVerified: true},
+		{title: "query.jwt", responseMode: "query.jwt", responseType: "code", wantDelivery: "query", wantVerified: true},
+		{title: "fragment.jwt", responseMode: "fragment.jwt", responseType: "code", wantDelivery: "fragment", wantVerified: true},
+		{title: "form_post.jwt", responseMode: "form_post.jwt", responseType: "code", wantDelivery: "form_post", wantVerified: true},
+		{title: "Wrong key", responseMode: "query.jwt", responseType: "code", useWrongKey: true, wantDelivery: "query"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.JARMConfig.Token.UseWrongKey = tc.useWrongKey
+			config.SetGlobalConfig(&c)
+
+			query := url.Values{
+				"client_id":     {"jarmclient"},
+				"redirect_uri":  {"https://localhost:8080/callback"},
+				"response_mode": {tc.responseMode},
+				"response_type": {tc.responseType},
+				"state":         {"jarmstate"},
+			}
+			req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+
+			var response string
+			switch tc.wantDelivery {
+			case "form_post":
+				if rr.Code != http.StatusOK {
+					t.Fatalf("authHandler() returned %d rather than expected 200", rr.Code)
+				}
+				m := regexp.MustCompile(`name='response' value='([^']*)'`).FindStringSubmatch(rr.Body.String())
+				if m == nil {
+					t.Fatalf("authHandler() returned a form without a response: %s", rr.Body.String())
+				}
+				response = m[1]
+			default:
+				if rr.Code != http.StatusFound {
+					t.Fatalf("authHandler() returned %d rather than expected 302", rr.Code)
+				}
+				location, err := url.Parse(rr.Header().Get("Location"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				params := location.Query()
+				if tc.wantDelivery == "fragment" {
+					params, _ = url.ParseQuery(location.Fragment)
+				}
+				if len(params) != 1 {
+					t.Errorf("authHandler() returned parameters besides the response: %v", params)
+				}
+				response = params.Get("response")
+			}
+
+			token, err := jwt.ParseString(response, jwt.WithVerify(jwa.RS256, pubKey))
+			if (err == nil) != tc.wantVerified {
+				t.Fatalf("authHandler() returned response token verification error %v, expected verified %v", err, tc.wantVerified)
+			}
+			if err != nil {
+				return
+			}
+
+			if state, _ := token.Get("state"); state != "jarmstate" {
+				t.Errorf("authHandler() returned state %v in the response token", state)
+			}
+			if _, ok := token.Get("code"); !ok {
+				t.Errorf("authHandler() returned no code in the response token")
+			}
+			if aud := token.Audience(); len(aud) != 1 || aud[0] != "jarmclient" {
+				t.Errorf("authHandler() returned aud %v in the response token", aud)
+			}
+		})
+	}
+}
+
+func TestAuthHandlerResponseMode(t *testing.T) {
+	cases := []struct {
+		title        string
+		responseMode string
+		// Requested response_mode parameter.
+		requested    string
+		wantDelivery string
+	}{
+		{title: "Requested form_post", responseMode: "requested", requested: "form_post", wantDelivery: "form_post"},
+		{title: "Requested fragment", responseMode: "requested", requested: "fragment", wantDelivery: "fragment"},
+		{title: "Default query", responseMode: "requested", wantDelivery: "query"},
+		{title: "Configured form_post", responseMode: "form_post", requested: "query", wantDelivery: "form_post"},
+		{title: "Configured query", responseMode: "query", requested: "form_post", wantDelivery: "query"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.AuthAction.Redirect.ResponseMode = tc.responseMode
+			config.SetGlobalConfig(&c)
+
+			query := url.Values{
+				"redirect_uri": {"https://localhost:8080/callback"},
+				"state":        {"modestate"},
+			}
+			if tc.requested != "" {
+				query.Set("response_mode", tc.requested)
+			}
+			req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+
+			if tc.wantDelivery == "form_post" {
+				if rr.Code != http.StatusOK {
+					t.Fatalf("authHandler() returned %d rather than expected 200", rr.Code)
+				}
+
+				csp := rr.Header().Get("Content-Security-Policy")
+				if !strings.Contains(csp, "default-src 'none'") || !strings.Contains(csp, "form-action https://localhost:8080") {
+					t.Errorf("authHandler() returned unexpected CSP %q", csp)
+				}
+
+				body := rr.Body.String()
+				for _, want := range []string{"action='https://localhost:8080/callback'", "name='state' value='modestate'", "name='code'"} {
+					if !strings.Contains(body, want) {
+						t.Errorf("authHandler() form is missing %q: %s", want, body)
+					}
+				}
+				return
+			}
+
+			if rr.Code != http.StatusFound {
+				t.Fatalf("authHandler() returned %d rather than expected 302", rr.Code)
+			}
+			location, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := location.Query()
+			if tc.wantDelivery == "fragment" {
+				params, _ = url.ParseQuery(location.Fragment)
+			}
+			if params.Get("state") != "modestate" {
+				t.Errorf("authHandler() returned state %q in the %s", params.Get("state"), tc.wantDelivery)
+			}
+		})
+	}
+}
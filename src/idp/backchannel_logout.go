@@ -0,0 +1,111 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// backchannelLogoutHandler shows a page to log a session out of the clients and
+// sends the back-channel logout tokens when it is submitted. This is protected by
+// authorization since it makes the IdP post to the clients.
+func backchannelLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkAuth(w, r) {
+		return
+	}
+
+	c := config.GetGlobalConfig()
+	input := getInputData(r)
+
+	page := &approvalPage{
+		Title:  "Back-Channel Logout",
+		Action: "/oauth2/backchannel-logout",
+		Field:  "sid",
+		Label:  "Session ID",
+		Value:  r.Form.Get("sid"),
+		Submit: "Log Out",
+	}
+
+	decision := ""
+	if r.Method == "POST" {
+		decision = "logout"
+		results := sendBackchannelLogout(c, input, r.Form.Get("client_id"), page.Value)
+		if len(results) == 0 {
+			page.Message = "No client has a back-channel logout URI."
+		} else {
+			page.Message = strings.Join(results, " ")
+		}
+	}
+	addRequestLogEntry(input, decision)
+	writeApprovalPage(w, page)
+}
+
+// sendBackchannelLogout sends a logout token for the session to the back-channel
+// logout URI of every configured or registered client, or only of the client with
+// the client ID if set.
+// The tokens are sent concurrently so a slow client doesn't hold up the others.
+// It returns the outcome for each client.
+func sendBackchannelLogout(c *config.Config, input *sessionmgmt.RequestInput, clientID string, sid string) []string {
+	all := c.AllClients()
+	var clients []*config.Client
+	for i := range all {
+		client := &all[i]
+		if client.BackchannelLogoutURI == "" || (clientID != "" && client.ClientID != clientID) {
+			continue
+		}
+		clients = append(clients, client)
+	}
+
+	results := make([]string, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *config.Client) {
+			defer wg.Done()
+			code, err := postLogoutToken(c, input, client, sid)
+			if err != nil {
+				results[i] = fmt.Sprintf("Logout of %s failed: %v.", client.ClientID, err)
+			} else {
+				results[i] = fmt.Sprintf("Logout of %s returned %d.", client.ClientID, code)
+			}
+		}(i, client)
+	}
+	wg.Wait()
+	return results
+}
+
+// postLogoutToken posts a logout token to the client's back-channel logout URI
+// and returns the response status code.
+func postLogoutToken(c *config.Config, input *sessionmgmt.RequestInput, client *config.Client, sid string) (int, error) {
+	logoutInput := *input
+	logoutInput.Session = &sessionmgmt.Session{ClientID: client.ClientID, SessionID: sid}
+	token, err := config.GenerateLogoutToken(&logoutInput, c)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := outboundClient.PostForm(client.BackchannelLogoutURI, url.Values{"logout_token": {token}})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackchannelLogout(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(userNameVar, "")
+	t.Setenv(pwdHashVar, "")
+
+	// Each client request waits for the others to arrive, so sequential sends
+	// are reported as not concurrent.
+	var mu sync.Mutex
+	var received []map[string]any
+	expected := 0
+	concurrent := true
+	client := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, err := keys.DecodeToken(r.PostFormValue("logout_token"))
+		if err != nil {
+			t.Errorf("client received invalid logout token: %v", err)
+		}
+		mu.Lock()
+		received = append(received, claims)
+		mu.Unlock()
+
+		for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			mu.Lock()
+			arrived := len(received)
+			mu.Unlock()
+			if arrived >= expected {
+				return
+			}
+			if time.Now().After(deadline) {
+				mu.Lock()
+				concurrent = false
+				mu.Unlock()
+				return
+			}
+		}
+	}))
+	defer client.Close()
+
+	cases := []struct {
+		title    string
+		path     string
+		form     url.Values
+		nonce    bool
+		wantAud  []string
+		wantBody string
+	}{
+		{
+			title:    "Admin logout of all clients",
+			path:     "/oauth2/backchannel-logout",
+			form:     url.Values{"sid": {"sid123"}},
+			wantAud:  []string{"rp", "otherrp"},
+			wantBody: "Logout of rp returned 200. Logout of otherrp returned 200.",
+		},
+		{
+			title:    "Admin logout of one client",
+			path:     "/oauth2/backchannel-logout",
+			form:     url.Values{"sid": {"sid123"}, "client_id": {"otherrp"}},
+			wantAud:  []string{"otherrp"},
+			wantBody: "Logout of otherrp returned 200.",
+		},
+		{
+			title:    "Admin logout of unknown client",
+			path:     "/oauth2/backchannel-logout",
+			form:     url.Values{"sid": {"sid123"}, "client_id": {"unknown"}},
+			wantBody: "No client has a back-channel logout URI.",
+		},
+		{
+			title:    "Logout token with nonce",
+			path:     "/oauth2/backchannel-logout",
+			form:     url.Values{"sid": {"sid123"}, "client_id": {"rp"}},
+			nonce:    true,
+			wantAud:  []string{"rp"},
+			wantBody: "Logout of rp returned 200.",
+		},
+		{
+			title:   "End session",
+			path:    "/oauth2/logout",
+			form:    url.Values{"id_token_hint": {signIDTokenHint(t, "rp", "sid123", false)}},
+			wantAud: []string{"rp", "otherrp"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.Clients = []config.Client{
+				{ClientID: "rp", BackchannelLogoutURI: client.URL},
+				{ClientID: "otherrp", BackchannelLogoutURI: client.URL},
+				{ClientID: "frontchannelrp"},
+			}
+			if tc.nonce {
+				c.BackchannelLogoutConfig.Token.Claims = append([]config.Claim{{ID: "nonce", Values: []string{"n-0S6_WzA2Mj"}}}, c.BackchannelLogoutConfig.Token.Claims...)
+			}
+			config.SetGlobalConfig(&c)
+			received = nil
+			expected = len(tc.wantAud)
+			concurrent = true
+
+			req, err := http.NewRequest("POST", "https://idp.idp"+tc.path, bytes.NewBufferString(tc.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			if tc.path == "/oauth2/logout" {
+				http.HandlerFunc(logoutHandler).ServeHTTP(rr, req)
+			} else {
+				http.HandlerFunc(backchannelLogoutHandler).ServeHTTP(rr, req)
+			}
+
+			if !strings.Contains(rr.Body.String(), tc.wantBody) {
+				t.Errorf("handler page is missing %q: %s", tc.wantBody, rr.Body.String())
+			}
+
+			if !concurrent {
+				t.Errorf("logout tokens were not sent concurrently")
+			}
+
+			if len(received) != len(tc.wantAud) {
+				t.Fatalf("clients received %d logout tokens, expected %d", len(received), len(tc.wantAud))
+			}
+			audiences := map[string]bool{}
+			for _, claims := range received {
+				for _, aud := range getAudience(claims["aud"]) {
+					audiences[aud] = true
+				}
+				if !audienceMatches(claims["aud"], tc.wantAud) || claims["sid"] != "sid123" || claims["iss"] != "https://idp.idp" {
+					t.Errorf("client received unexpected logout token claims %v", claims)
+				}
+				if _, ok := claims["nonce"]; ok != tc.nonce {
+					t.Errorf("client received logout token with nonce %v", claims["nonce"])
+				}
+			}
+			if len(audiences) != len(tc.wantAud) {
+				t.Errorf("logout tokens were sent to %v, expected %v", audiences, tc.wantAud)
+			}
+		})
+	}
+}
+
+func TestBackchannelLogoutRequiresAuth(t *testing.T) {
+	setupCreds(t)
+	defer os.Unsetenv(userNameVar)
+	defer os.Unsetenv(pwdHashVar)
+
+	notified := false
+	client := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notified = true
+	}))
+	defer client.Close()
+
+	c := config.DefaultConfig
+	c.Clients = []config.Client{{ClientID: "rp", BackchannelLogoutURI: client.URL}}
+	config.SetGlobalConfig(&c)
+
+	req, err := http.NewRequest("POST", "https://idp.idp/oauth2/backchannel-logout", bytes.NewBufferString(url.Values{"sid": {"sid123"}}.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(backchannelLogoutHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("backchannelLogoutHandler() returned %d rather than expected 401", rr.Code)
+	}
+	if notified {
+		t.Errorf("backchannelLogoutHandler() sent a logout token without authorization")
+	}
+}
+
+func TestBackchannelLogoutRegisteredClient(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	notified := false
+	client := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notified = true
+	}))
+	defer client.Close()
+
+	sessionmgmt.AddRegisteredClient(sessionmgmt.RegisteredClient{
+		ClientID: "dcrrp",
+		Metadata: map[string]any{"backchannel_logout_uri": client.URL},
+	})
+	defer sessionmgmt.DeleteRegisteredClient("dcrrp")
+
+	c := config.DefaultConfig
+	results := sendBackchannelLogout(&c, &sessionmgmt.RequestInput{Domain: "idp.idp"}, "dcrrp", "sid123")
+	if len(results) != 1 || results[0] != "Logout of dcrrp returned 200." {
+		t.Errorf("sendBackchannelLogout() returned %v, expected a logout of the registered client", results)
+	}
+	if !notified {
+		t.Errorf("registered client did not receive a logout token")
+	}
+}
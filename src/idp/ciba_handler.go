@@ -0,0 +1,220 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// cibaGrantType is the CIBA grant_type for polling the Token endpoint.
+const cibaGrantType = "urn:openid:params:grant-type:ciba"
+
+// cibaHandler takes action for the Backchannel Authentication Endpoint based on config.
+func cibaHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().CIBAAction
+	input := getInputData(r)
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		cibaRespond(w, r, input, &action)
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// cibaRespond responds with JSON content as configured and tracks the issued auth_req_id.
+// With auto approve, ping and push notifications are sent after responding.
+func cibaRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput, action *config.CIBAAction) {
+	notificationToken := input.FormParams.Get("client_notification_token")
+	if action.DeliveryMode != "poll" && notificationToken == "" {
+		oauthErrorResponse(w, http.StatusBadRequest, "invalid_request", "missing client_notification_token")
+		return
+	}
+
+	content, err := getJSONContent(input, action.Respond.Parameters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authReqID, _ := content["auth_req_id"].(string)
+	if authReqID != "" {
+		auth := sessionmgmt.BackchannelAuthorization{
+			AuthReqID:         authReqID,
+			Status:            "pending",
+			NotificationToken: notificationToken,
+			Session: sessionmgmt.Session{
+				Code:     authReqID,
+				ClientID: getClientID(input),
+			},
+		}
+		if expiresIn, ok := content["expires_in"].(int); ok {
+			auth.ExpiresAt = input.Time.Add(time.Duration(expiresIn) * time.Second)
+		}
+		if action.AutoApprove {
+			auth.Status = "approved"
+		}
+		sessionmgmt.AddBackchannelAuthorization(auth)
+
+		if action.AutoApprove && action.DeliveryMode != "poll" {
+			notifyInput := *input
+			go func() {
+				if _, err := notifyClient(action, &auth, &notifyInput); err != nil {
+					logError(fmt.Sprintf("CIBA notification failed: %v", err), r)
+				}
+			}()
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, http.StatusOK, content)
+}
+
+// cibaApproveHandler shows the approval page and records the tester's decision.
+// In ping and push modes the client is notified of the decision.
+func cibaApproveHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().CIBAAction
+	input := getInputData(r)
+
+	page := &approvalPage{
+		Title:  "Backchannel Authentication",
+		Action: "/oauth2/bc-approve",
+		Field:  "auth_req_id",
+		Label:  "Auth Request ID",
+		Value:  r.Form.Get("auth_req_id"),
+	}
+
+	decision := ""
+	if r.Method == "POST" {
+		decision = r.PostForm.Get("decision")
+		status := "denied"
+		if decision == "approve" {
+			status = "approved"
+		}
+
+		auth, err := sessionmgmt.SetBackchannelStatus(page.Value, status)
+		if err != nil {
+			page.Message = err.Error()
+		} else if action.DeliveryMode == "poll" {
+			page.Message = "Request " + status + "."
+		} else if code, err := notifyClient(&action, &auth, input); err != nil {
+			page.Message = fmt.Sprintf("Request %s, notification failed: %v", status, err)
+		} else {
+			page.Message = fmt.Sprintf("Request %s, notification returned %d.", status, code)
+		}
+	}
+	addRequestLogEntry(input, decision)
+	writeApprovalPage(w, page)
+}
+
+// notifyClient sends the ping or push notification to the client notification endpoint
+// and returns the response status code.
+func notifyClient(action *config.CIBAAction, auth *sessionmgmt.BackchannelAuthorization, input *sessionmgmt.RequestInput) (int, error) {
+	c := action.Notification
+	if c.Endpoint == "" {
+		return 0, errors.New("no client notification endpoint configured")
+	}
+
+	notifyInput := *input
+	notifyInput.Session = &auth.Session
+	content, err := getJSONContent(&notifyInput, c.Parameters)
+	if err != nil {
+		return 0, err
+	}
+
+	// A delivered push has the result, so the Token endpoint no longer does, unless
+	// no access_token was pushed and the client has to fetch the tokens.
+	pushed := false
+	if action.DeliveryMode == "push" {
+		_, pushed = content["access_token"]
+		if auth.Status == "denied" {
+			content["error"] = "access_denied"
+			pushed = true
+		}
+	}
+
+	body, err := json.Marshal(content)
+	if err != nil {
+		return 0, err
+	}
+	if c.MalformedBody {
+		body = body[:len(body)/2]
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	token := auth.NotificationToken
+	if c.UseWrongToken {
+		if token, err = generateBase64ID(32); err != nil {
+			return 0, err
+		}
+	}
+	if !c.OmitAuthorization {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if pushed && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		sessionmgmt.UpdateBackchannelAuthorization(auth.AuthReqID, func(auth *sessionmgmt.BackchannelAuthorization) error {
+			auth.Status = "issued"
+			return nil
+		})
+	}
+	return resp.StatusCode, nil
+}
+
+// pollCIBA checks a CIBA grant and returns the error code to respond with, or an
+// empty string if tokens should be issued.
+func pollCIBA(input *sessionmgmt.RequestInput) string {
+	errCode := "invalid_grant"
+	sessionmgmt.UpdateBackchannelAuthorization(input.FormParams.Get("auth_req_id"), func(auth *sessionmgmt.BackchannelAuthorization) error {
+		switch {
+		case auth.Status == "issued":
+			errCode = "invalid_grant"
+		case !auth.ExpiresAt.IsZero() && input.Time.After(auth.ExpiresAt):
+			errCode = "expired_token"
+		case auth.Status == "denied":
+			errCode = "access_denied"
+		case auth.Status == "pending":
+			errCode = "authorization_pending"
+		default:
+			errCode = ""
+			auth.Status = "issued"
+			return nil
+		}
+		return errors.New(errCode)
+	})
+	return errCode
+}
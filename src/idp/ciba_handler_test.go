@@ -0,0 +1,238 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCIBAFlow(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotAuth string
+	var gotBody []byte
+	notified := false
+	notifyStatus := http.StatusNoContent
+	client := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notified = true
+		gotAuth = r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(notifyStatus)
+	}))
+	defer client.Close()
+
+	cases := []struct {
+		title        string
+		mode         string
+		notification config.CIBANotification
+		decision     string
+		notifyStatus int
+		wantAuth     string
+		wantBody     map[string]any
+		wantMalform  bool
+		wantPoll     string
+	}{
+		{
+			title:    "Poll",
+			mode:     "poll",
+			decision: "approve",
+		},
+		{
+			title:    "Poll denied",
+			mode:     "poll",
+			decision: "deny",
+			wantPoll: "access_denied",
+		},
+		{
+			title:    "Ping",
+			mode:     "ping",
+			decision: "approve",
+			wantAuth: "Bearer notifytoken",
+			wantBody: map[string]any{"auth_req_id": ""},
+		},
+		{
+			title: "Ping mismatched auth_req_id without authorization",
+			mode:  "ping",
+			notification: config.CIBANotification{
+				Parameters: []config.Parameter{
+					{ID: "auth_req_id", Action: "set", Values: []string{"mismatched"}, JSONType: "string"},
+				},
+				OmitAuthorization: true,
+			},
+			decision: "approve",
+			wantBody: map[string]any{"auth_req_id": "mismatched"},
+		},
+		{
+			title:    "Push without tokens",
+			mode:     "push",
+			decision: "approve",
+			wantAuth: "Bearer notifytoken",
+			wantBody: map[string]any{"auth_req_id": ""},
+		},
+		{
+			title:    "Push denied",
+			mode:     "push",
+			decision: "deny",
+			wantAuth: "Bearer notifytoken",
+			wantBody: map[string]any{"auth_req_id": "", "error": "access_denied"},
+			wantPoll: "invalid_grant",
+		},
+		{
+			title: "Push not delivered",
+			mode:  "push",
+			notification: config.CIBANotification{
+				Parameters: []config.Parameter{
+					{ID: "access_token", Action: "random", JSONType: "string"},
+				},
+			},
+			decision:     "approve",
+			notifyStatus: http.StatusInternalServerError,
+			wantAuth:     "Bearer notifytoken",
+		},
+		{
+			title: "Push malformed",
+			mode:  "push",
+			notification: config.CIBANotification{
+				Parameters: []config.Parameter{
+					{ID: "access_token", Action: "random", JSONType: "string"},
+				},
+				MalformedBody: true,
+			},
+			decision:    "approve",
+			wantAuth:    "Bearer notifytoken",
+			wantMalform: true,
+			wantPoll:    "invalid_grant",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			notified = false
+			notifyStatus = http.StatusNoContent
+			if tc.notifyStatus != 0 {
+				notifyStatus = tc.notifyStatus
+			}
+			c := config.DefaultConfig
+			c.CIBAAction.DeliveryMode = tc.mode
+			if tc.notification.Parameters != nil {
+				c.CIBAAction.Notification = tc.notification
+			}
+			c.CIBAAction.Notification.Endpoint = client.URL
+			config.SetGlobalConfig(&c)
+
+			form := url.Values{"client_id": {"cibaclient"}, "scope": {"openid"}, "login_hint": {"user"}, "client_notification_token": {"notifytoken"}}
+			req, err := http.NewRequest("POST", "/oauth2/bc-authorize", bytes.NewBufferString(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(cibaHandler).ServeHTTP(rr, req)
+			if rr.Code != 200 {
+				t.Fatalf("cibaHandler() returned %d rather than expected 200", rr.Code)
+			}
+
+			var auth map[string]any
+			if err = json.Unmarshal(rr.Body.Bytes(), &auth); err != nil {
+				t.Fatalf("Failed to parse json data returned from cibaHandler() %v", err)
+			}
+			authReqID := auth["auth_req_id"].(string)
+
+			_, results := postTokenRequest(t, url.Values{"grant_type": {cibaGrantType}, "auth_req_id": {authReqID}})
+			if results["error"] != "authorization_pending" {
+				t.Errorf("poll before approval returned %v, expected authorization_pending", results)
+			}
+
+			form = url.Values{"auth_req_id": {authReqID}, "decision": {tc.decision}}
+			req, err = http.NewRequest("POST", "/oauth2/bc-approve", bytes.NewBufferString(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			http.HandlerFunc(cibaApproveHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+			if notified != (tc.mode != "poll") {
+				t.Fatalf("client notified is %v for %s mode", notified, tc.mode)
+			}
+
+			if notified {
+				if gotAuth != tc.wantAuth {
+					t.Errorf("notification Authorization %q, expected %q", gotAuth, tc.wantAuth)
+				}
+
+				var gotResults map[string]any
+				err := json.Unmarshal(gotBody, &gotResults)
+				if tc.wantMalform != (err != nil) {
+					t.Errorf("notification body %q, expected malformed %v", gotBody, tc.wantMalform)
+				}
+
+				for k, v := range tc.wantBody {
+					if v == "" {
+						v = authReqID
+					}
+					if gotResults[k] != v {
+						t.Errorf("notification %q is %v, expected %v", k, gotResults[k], v)
+					}
+				}
+			}
+
+			gotCode, results := postTokenRequest(t, url.Values{"grant_type": {cibaGrantType}, "auth_req_id": {authReqID}})
+			gotError, _ := results["error"].(string)
+			if gotError != tc.wantPoll {
+				t.Errorf("poll after decision returned error %q, expected %q", gotError, tc.wantPoll)
+			}
+
+			if gotError == "" && gotCode != 200 {
+				t.Errorf("poll after decision returned %d rather than expected 200", gotCode)
+			}
+		})
+	}
+}
+
+func TestConcurrentCIBAPolls(t *testing.T) {
+	sessionmgmt.AddBackchannelAuthorization(sessionmgmt.BackchannelAuthorization{AuthReqID: "concurrent", Status: "approved"})
+	input := &sessionmgmt.RequestInput{FormParams: url.Values{"auth_req_id": {"concurrent"}}}
+
+	var wg sync.WaitGroup
+	var issued atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if pollCIBA(input) == "" {
+				issued.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := issued.Load(); got != 1 {
+		t.Errorf("pollCIBA() issued tokens %d times for one approval, expected once", got)
+	}
+}
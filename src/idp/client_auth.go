@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"crypto/subtle"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clientAssertionType is the RFC 7523 client assertion type of JWT client authentication.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// getRequestClientID returns the client_id of HTTP Basic authentication, the
+// client_id parameter, or the issuer of a client assertion.
+func getRequestClientID(r *http.Request) string {
+	if username, _, ok := r.BasicAuth(); ok {
+		if clientID, err := url.QueryUnescape(username); err == nil {
+			return clientID
+		}
+		return username
+	}
+
+	if clientID := r.Form.Get("client_id"); clientID != "" {
+		return clientID
+	}
+
+	if assertion := decodeJWT(r.Form.Get("client_assertion")); assertion != nil {
+		iss, _ := assertion.Claims["iss"].(string)
+		return iss
+	}
+	return ""
+}
+
+// getClientAuthMethod returns the token_endpoint_auth_method the request used.
+func getClientAuthMethod(r *http.Request) string {
+	if _, _, ok := r.BasicAuth(); ok {
+		return "client_secret_basic"
+	}
+
+	if r.PostForm.Get("client_assertion_type") == clientAssertionType {
+		assertion := decodeJWT(r.PostForm.Get("client_assertion"))
+		if assertion != nil {
+			if alg, _ := assertion.Header["alg"].(string); strings.HasPrefix(alg, "HS") {
+				return "client_secret_jwt"
+			}
+		}
+		return "private_key_jwt"
+	}
+
+	if r.PostForm.Get("client_secret") != "" {
+		return "client_secret_post"
+	}
+	return "none"
+}
+
+// authenticateClient checks the client authentication of a token request
+// against the registered client, or the client of the session if the request
+// doesn't identify one. Clients that aren't registered are not authenticated.
+// Each check can be skipped per client to misbehave.
+func authenticateClient(c *config.Config, r *http.Request, input *sessionmgmt.RequestInput) error {
+	clientID := input.ClientID
+	if clientID == "" && input.Session != nil {
+		clientID = input.Session.ClientID
+	}
+	client, ok := c.GetClient(clientID)
+	if !ok {
+		return nil
+	}
+
+	method := getClientAuthMethod(r)
+	if method == "none" && len(input.ClientCertificates) != 0 {
+		method = "tls_client_auth"
+		if client.TokenEndpointAuthMethod == "self_signed_tls_client_auth" {
+			method = client.TokenEndpointAuthMethod
+		}
+	}
+	if method != client.TokenEndpointAuthMethod && !client.SkipAuthMethodCheck {
+		return fmt.Errorf("client authenticated with %s, expected %s", method, client.TokenEndpointAuthMethod)
+	}
+
+	switch method {
+	case "client_secret_basic":
+		_, secret, _ := r.BasicAuth()
+		if unescaped, err := url.QueryUnescape(secret); err == nil {
+			secret = unescaped
+		}
+		return checkClientSecret(&client, secret)
+	case "client_secret_post":
+		return checkClientSecret(&client, r.PostForm.Get("client_secret"))
+	case "client_secret_jwt", "private_key_jwt":
+		return checkClientAssertion(&client, method, input, r.PostForm.Get("client_assertion"))
+	case "tls_client_auth":
+		return checkTLSClientAuth(&c.MTLSConfig, &client, input)
+	case "self_signed_tls_client_auth":
+		return checkSelfSignedTLSClientAuth(&client, input)
+	}
+	return nil
+}
+
+// checkClientSecret compares the presented secret with the client secret.
+func checkClientSecret(client *config.Client, secret string) error {
+	if client.SkipSecretCheck {
+		return nil
+	}
+
+	if client.ClientSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(client.ClientSecret)) != 1 {
+		return errors.New("invalid client secret")
+	}
+	return nil
+}
+
+// checkClientAssertion validates a client_secret_jwt or private_key_jwt client
+// assertion per RFC 7523 Section 3. client_secret_jwt assertions are verified
+// with the client secret, private_key_jwt assertions with the client JWKS.
+func checkClientAssertion(client *config.Client, method string, input *sessionmgmt.RequestInput, raw string) error {
+	assertion := decodeJWT(raw)
+	if assertion == nil {
+		return errors.New("missing or malformed client assertion")
+	}
+
+	if !client.SkipSignatureCheck {
+		alg, _ := assertion.Header["alg"].(string)
+		if client.TokenEndpointAuthSigningAlg != "" && alg != client.TokenEndpointAuthSigningAlg {
+			return fmt.Errorf("client assertion is signed with %s, expected %s", alg, client.TokenEndpointAuthSigningAlg)
+		}
+
+		jwks := client.JWKS
+		if method == "client_secret_jwt" {
+			jwks = secretKeySet(client.ClientSecret)
+		}
+		if err := keys.VerifyWithKeySet(assertion.Raw, jwks); err != nil {
+			return fmt.Errorf("client assertion signature is invalid: %v", err)
+		}
+	}
+
+	if client.SkipAssertionCheck {
+		return nil
+	}
+
+	for _, claim := range []string{"iss", "sub"} {
+		if val, _ := assertion.Claims[claim].(string); val != client.ClientID {
+			return fmt.Errorf("client assertion %s is not the client_id", claim)
+		}
+	}
+
+	exp, ok := assertion.Claims["exp"].(float64)
+	if !ok || input.Time.After(time.Unix(int64(exp), 0)) {
+		return errors.New("client assertion is expired")
+	}
+
+	issuer := "https://" + input.Domain
+	if !audienceMatches(assertion.Claims["aud"], []string{issuer, issuer + "/oauth2/token"}) {
+		return errors.New("client assertion audience is not accepted")
+	}
+	return nil
+}
+
+// secretKeySet returns a JSON Key Set holding the client secret as a symmetric key.
+func secretKeySet(secret string) string {
+	set := map[string]any{
+		"keys": []map[string]string{{"kty": "oct", "k": base64.RawURLEncoding.EncodeToString([]byte(secret))}},
+	}
+	b, _ := json.Marshal(set)
+	return string(b)
+}
@@ -0,0 +1,281 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/keys"
+	"customidp/session"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// clientAssertion creates a client assertion for the token endpoint, signed
+// with the client secret for HS256 or the IdP keys otherwise, or the wrong IdP
+// key with useWrongKey.
+func clientAssertion(t *testing.T, clientID string, alg string, secret string, useWrongKey bool, exp time.Time) string {
+	t.Helper()
+	token := jwt.New()
+	token.Set("iss", clientID)
+	token.Set("sub", clientID)
+	token.Set("aud", "https://idp.idp/oauth2/token")
+	token.Set("jti", "assertion-jti")
+	token.Set("exp", exp.Unix())
+
+	if alg == "HS256" {
+		signed, err := jwt.Sign(token, jwa.HS256, []byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(signed)
+	}
+
+	signed, err := keys.SignToken(alg, token, useWrongKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestClientAuthentication(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := keys.GetJSONKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	earlier := time.Now().Add(-time.Minute)
+	assertionForm := func(assertion string) url.Values {
+		return url.Values{"grant_type": {"client_credentials"}, "client_assertion_type": {clientAssertionType}, "client_assertion": {assertion}}
+	}
+
+	cases := []struct {
+		title    string
+		client   config.Client
+		form     url.Values
+		basic    []string
+		wantCode int
+	}{
+		{
+			title:    "Unregistered client",
+			client:   config.Client{ClientID: "basic", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_basic"},
+			form:     url.Values{"grant_type": {"client_credentials"}, "client_id": {"unregistered"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Client secret basic",
+			client:   config.Client{ClientID: "basic", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_basic"},
+			form:     url.Values{"grant_type": {"client_credentials"}},
+			basic:    []string{"basic", "s3cret"},
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Credential-less code redemption",
+			client:   config.Client{ClientID: "basic", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_basic"},
+			form:     url.Values{"grant_type": {"authorization_code"}, "code": {"basiccode"}},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Public client code redemption",
+			client:   config.Client{ClientID: "public", TokenEndpointAuthMethod: "none"},
+			form:     url.Values{"grant_type": {"authorization_code"}, "code": {"publiccode"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Wrong client secret basic",
+			client:   config.Client{ClientID: "basic", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_basic"},
+			form:     url.Values{"grant_type": {"client_credentials"}},
+			basic:    []string{"basic", "wrong"},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Wrong client secret accepted",
+			client:   config.Client{ClientID: "basic", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_basic", SkipSecretCheck: true},
+			form:     url.Values{"grant_type": {"client_credentials"}},
+			basic:    []string{"basic", "wrong"},
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Unexpected auth method",
+			client:   config.Client{ClientID: "basic", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_basic"},
+			form:     url.Values{"grant_type": {"client_credentials"}, "client_id": {"basic"}, "client_secret": {"s3cret"}},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Unexpected auth method accepted",
+			client:   config.Client{ClientID: "basic", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_basic", SkipAuthMethodCheck: true},
+			form:     url.Values{"grant_type": {"client_credentials"}, "client_id": {"basic"}, "client_secret": {"s3cret"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Missing authentication",
+			client:   config.Client{ClientID: "post", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_post"},
+			form:     url.Values{"grant_type": {"client_credentials"}, "client_id": {"post"}},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Client secret post",
+			client:   config.Client{ClientID: "post", ClientSecret: "s3cret", TokenEndpointAuthMethod: "client_secret_post"},
+			form:     url.Values{"grant_type": {"client_credentials"}, "client_id": {"post"}, "client_secret": {"s3cret"}},
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Client secret JWT",
+			client:   config.Client{ClientID: "hmac", ClientSecret: "a-long-shared-client-secret-value", TokenEndpointAuthMethod: "client_secret_jwt"},
+			form:     assertionForm(clientAssertion(t, "hmac", "HS256", "a-long-shared-client-secret-value", false, later)),
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Client secret JWT with wrong secret",
+			client:   config.Client{ClientID: "hmac", ClientSecret: "a-long-shared-client-secret-value", TokenEndpointAuthMethod: "client_secret_jwt"},
+			form:     assertionForm(clientAssertion(t, "hmac", "HS256", "another-shared-client-secret", false, later)),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Private key JWT",
+			client:   config.Client{ClientID: "pkjwt", JWKS: jwks, TokenEndpointAuthMethod: "private_key_jwt"},
+			form:     assertionForm(clientAssertion(t, "pkjwt", "RS256", "", false, later)),
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Private key JWT with wrong key",
+			client:   config.Client{ClientID: "pkjwt", JWKS: jwks, TokenEndpointAuthMethod: "private_key_jwt"},
+			form:     assertionForm(clientAssertion(t, "pkjwt", "RS256", "", true, later)),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Private key JWT with wrong key accepted",
+			client:   config.Client{ClientID: "pkjwt", JWKS: jwks, TokenEndpointAuthMethod: "private_key_jwt", SkipSignatureCheck: true},
+			form:     assertionForm(clientAssertion(t, "pkjwt", "RS256", "", true, later)),
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Private key JWT with unexpected algorithm",
+			client:   config.Client{ClientID: "pkjwt", JWKS: jwks, TokenEndpointAuthMethod: "private_key_jwt", TokenEndpointAuthSigningAlg: "ES256"},
+			form:     assertionForm(clientAssertion(t, "pkjwt", "RS256", "", false, later)),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Expired client assertion",
+			client:   config.Client{ClientID: "pkjwt", JWKS: jwks, TokenEndpointAuthMethod: "private_key_jwt"},
+			form:     assertionForm(clientAssertion(t, "pkjwt", "ES256", "", false, earlier)),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Expired client assertion accepted",
+			client:   config.Client{ClientID: "pkjwt", JWKS: jwks, TokenEndpointAuthMethod: "private_key_jwt", SkipAssertionCheck: true},
+			form:     assertionForm(clientAssertion(t, "pkjwt", "ES256", "", false, earlier)),
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.Clients = []config.Client{tc.client}
+			config.SetGlobalConfig(&c)
+
+			// Codes are issued to the client under test.
+			if code := tc.form.Get("code"); code != "" {
+				err := session.CreateSession(&session.RequestInput{URLParams: url.Values{"client_id": {tc.client.ClientID}}}, url.Values{"code": {code}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			req, err := http.NewRequest("POST", "https://idp.idp/oauth2/token", bytes.NewBufferString(tc.form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basic != nil {
+				req.SetBasicAuth(tc.basic[0], tc.basic[1])
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(tokenHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("tokenHandler() returned %d rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+
+			if tc.wantCode == http.StatusUnauthorized {
+				var content map[string]any
+				json.Unmarshal(rr.Body.Bytes(), &content)
+				if content["error"] != "invalid_client" {
+					t.Errorf("tokenHandler() returned error %v rather than invalid_client", content["error"])
+				}
+			}
+		})
+	}
+}
+
+func TestClientPreferences(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.DefaultConfig
+	c.Clients = []config.Client{{
+		ClientID:                 "registered",
+		RedirectURIs:             []string{"https://client.example/cb"},
+		TokenEndpointAuthMethod:  "none",
+		IDTokenSignedResponseAlg: "ES256",
+	}}
+	config.SetGlobalConfig(&c)
+
+	for _, tc := range []struct {
+		redirectURI string
+		wantCode    int
+	}{
+		{redirectURI: "https://client.example/cb", wantCode: http.StatusFound},
+		{redirectURI: "https://attacker.example/cb", wantCode: http.StatusBadRequest},
+	} {
+		query := url.Values{"client_id": {"registered"}, "redirect_uri": {tc.redirectURI}, "response_type": {"code"}}
+		req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+		if rr.Code != tc.wantCode {
+			t.Errorf("authHandler() returned %d for %s rather than expected %d", rr.Code, tc.redirectURI, tc.wantCode)
+		}
+	}
+
+	code, content := postTokenRequest(t, url.Values{"grant_type": {"client_credentials"}, "client_id": {"registered"}})
+	if code != http.StatusOK {
+		t.Fatalf("tokenHandler() returned %d: %v", code, content)
+	}
+
+	header, _, err := keys.DecodeToken(content["id_token"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header["alg"] != "ES256" {
+		t.Errorf("tokenHandler() signed the ID token with %v rather than the client's ES256", header["alg"])
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"net/http"
+	"time"
+)
+
+// deviceCodeGrantType is the RFC 8628 grant_type for device code polling.
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceAuthHandler takes action for the Device Authorization Endpoint based on config.
+func deviceAuthHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().DeviceAction
+	input := getInputData(r)
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		deviceRespond(w, input)
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// deviceRespond responds with JSON content as configured and tracks the issued
+// device_code and user_code for later verification and polling.
+func deviceRespond(w http.ResponseWriter, input *sessionmgmt.RequestInput) {
+	c := config.GetGlobalConfig().DeviceAction.Respond
+	content, err := getJSONContent(input, c.Parameters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deviceCode, _ := content["device_code"].(string)
+	if deviceCode != "" {
+		userCode, _ := content["user_code"].(string)
+		device := sessionmgmt.DeviceAuthorization{
+			DeviceCode: deviceCode,
+			UserCode:   userCode,
+			Status:     "pending",
+			Interval:   5 * time.Second,
+			Session: sessionmgmt.Session{
+				Code:     deviceCode,
+				ClientID: getClientID(input),
+			},
+		}
+		if interval, ok := content["interval"].(int); ok {
+			device.Interval = time.Duration(interval) * time.Second
+		}
+		if expiresIn, ok := content["expires_in"].(int); ok {
+			device.ExpiresAt = input.Time.Add(time.Duration(expiresIn) * time.Second)
+		}
+		sessionmgmt.AddDeviceAuthorization(device)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, http.StatusOK, content)
+}
+
+// deviceVerifyHandler shows the verification page and records the tester's decision.
+func deviceVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	input := getInputData(r)
+
+	page := &approvalPage{
+		Title:  "Device Verification",
+		Action: "/oauth2/device",
+		Field:  "user_code",
+		Label:  "User Code",
+		Value:  r.Form.Get("user_code"),
+	}
+
+	decision := ""
+	if r.Method == "POST" {
+		decision = r.PostForm.Get("decision")
+		status := "denied"
+		if decision == "approve" {
+			status = "approved"
+		}
+
+		if err := sessionmgmt.SetDeviceStatus(page.Value, status); err != nil {
+			page.Message = err.Error()
+		} else {
+			page.Message = "Device " + status + "."
+		}
+	}
+	addRequestLogEntry(input, decision)
+	writeApprovalPage(w, page)
+}
+
+// pollDeviceCode checks a device_code grant and returns the RFC 8628 error code to
+// respond with, or an empty string if tokens should be issued.
+func pollDeviceCode(c *config.DevicePolling, input *sessionmgmt.RequestInput) string {
+	errCode := "invalid_grant"
+	sessionmgmt.UpdateDeviceAuthorization(input.FormParams.Get("device_code"), func(device *sessionmgmt.DeviceAuthorization) {
+		if device.Status == "issued" {
+			return
+		}
+
+		poll := device.Polls
+		lastPoll := device.LastPoll
+		device.Polls++
+		device.LastPoll = input.Time
+
+		errCode = devicePollError(c, device, poll, lastPoll, input.Time)
+		if errCode == "" {
+			device.Status = "issued"
+		}
+	})
+	return errCode
+}
+
+// devicePollError returns the RFC 8628 error code for a poll of the device
+// authorization, or an empty string if tokens should be issued.
+func devicePollError(c *config.DevicePolling, device *sessionmgmt.DeviceAuthorization, poll int, lastPoll time.Time, now time.Time) string {
+	if poll < len(c.Schedule) && c.Schedule[poll] != "respond" {
+		return c.Schedule[poll]
+	}
+
+	if poll >= len(c.Schedule) {
+		if !device.ExpiresAt.IsZero() && now.After(device.ExpiresAt) {
+			return "expired_token"
+		}
+
+		if c.EnforceInterval && !lastPoll.IsZero() && now.Sub(lastPoll) < device.Interval {
+			return "slow_down"
+		}
+
+		switch device.Status {
+		case "denied":
+			return "access_denied"
+		case "pending":
+			return "authorization_pending"
+		}
+	}
+
+	return ""
+}
+
+// getClientID gets the client_id from the form or URL parameters.
+func getClientID(input *sessionmgmt.RequestInput) string {
+	if clientID := input.FormParams.Get("client_id"); clientID != "" {
+		return clientID
+	}
+	return input.URLParams.Get("client_id")
+}
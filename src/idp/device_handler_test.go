@@ -0,0 +1,141 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceFlow(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		title    string
+		polling  config.DevicePolling
+		decision string
+		// Whether the tester decides before each poll.
+		decideBefore []bool
+		wantErrors   []string
+	}{
+		{
+			title:        "Approved",
+			decision:     "approve",
+			decideBefore: []bool{false, true, false},
+			wantErrors:   []string{"authorization_pending", "", "invalid_grant"},
+		},
+		{
+			title:        "Denied",
+			decision:     "deny",
+			decideBefore: []bool{true},
+			wantErrors:   []string{"access_denied"},
+		},
+		{
+			title:        "Schedule",
+			polling:      config.DevicePolling{Schedule: []string{"slow_down", "expired_token", "respond"}},
+			decideBefore: []bool{false, false, false},
+			wantErrors:   []string{"slow_down", "expired_token", ""},
+		},
+		{
+			title:        "Enforce interval",
+			polling:      config.DevicePolling{EnforceInterval: true},
+			decideBefore: []bool{false, false},
+			wantErrors:   []string{"authorization_pending", "slow_down"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.DeviceAction.Polling = tc.polling
+			config.SetGlobalConfig(&c)
+
+			form := url.Values{"client_id": {"deviceclient"}, "scope": {"openid"}}
+			req, err := http.NewRequest("POST", "https://idp.idp/oauth2/device_authorization", bytes.NewBufferString(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(deviceAuthHandler).ServeHTTP(rr, req)
+			if rr.Code != 200 {
+				t.Fatalf("deviceAuthHandler() returned %d rather than expected 200", rr.Code)
+			}
+
+			var device map[string]any
+			if err = json.Unmarshal(rr.Body.Bytes(), &device); err != nil {
+				t.Fatalf("Failed to parse json data returned from deviceAuthHandler() %v", err)
+			}
+
+			if device["verification_uri"] != "https://idp.idp/oauth2/device" {
+				t.Errorf("deviceAuthHandler() returned unexpected verification_uri %v", device["verification_uri"])
+			}
+
+			for i, decide := range tc.decideBefore {
+				if decide {
+					form := url.Values{"user_code": {device["user_code"].(string)}, "decision": {tc.decision}}
+					req, err := http.NewRequest("POST", "/oauth2/device", bytes.NewBufferString(form.Encode()))
+					if err != nil {
+						t.Fatal(err)
+					}
+					req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+					rr := httptest.NewRecorder()
+					http.HandlerFunc(deviceVerifyHandler).ServeHTTP(rr, req)
+					if !strings.Contains(rr.Body.String(), "Device ") {
+						t.Fatalf("deviceVerifyHandler() did not record the decision: %s", rr.Body.String())
+					}
+				}
+
+				gotCode, results := postTokenRequest(t, url.Values{
+					"grant_type":  {deviceCodeGrantType},
+					"device_code": {device["device_code"].(string)},
+				})
+
+				gotError, _ := results["error"].(string)
+				if gotError != tc.wantErrors[i] {
+					t.Errorf("poll %d returned error %q, expected %q", i, gotError, tc.wantErrors[i])
+				}
+
+				if gotError == "" && gotCode != 200 {
+					t.Errorf("poll %d returned %d rather than expected 200", i, gotCode)
+				}
+			}
+		})
+	}
+}
+
+func TestDevicePollError(t *testing.T) {
+	now := time.Now()
+	device := sessionmgmt.DeviceAuthorization{Status: "pending", Interval: 5 * time.Second, ExpiresAt: now.Add(-time.Second)}
+
+	// An expired device_code is reported before polling too fast.
+	got := devicePollError(&config.DevicePolling{EnforceInterval: true}, &device, 1, now.Add(-time.Second), now)
+	if got != "expired_token" {
+		t.Errorf("devicePollError() returned %q, expected expired_token", got)
+	}
+}
@@ -18,8 +18,12 @@ import (
 	"customidp/config"
 	sessionmgmt "customidp/session"
 	"net/http"
+	"strings"
 )
 
+// oauthMetadataPath is the well-known path of OAuth 2.0 Authorization Server Metadata.
+const oauthMetadataPath = "/.well-known/oauth-authorization-server"
+
 // discHandler returns OIDC Discovery doc.
 func discHandler(w http.ResponseWriter, r *http.Request) {
 	action := config.GetGlobalConfig().DiscoveryAction
@@ -45,3 +49,33 @@ func discRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.Requ
 
 	jsonResponse(w, input, respond.Parameters)
 }
+
+// oauthMetadataHandler returns the OAuth 2.0 Authorization Server Metadata
+// (RFC 8414). The issuer path of issuers with a path is inserted after the
+// well-known path, and is available to templates as IssuerPath.
+func oauthMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	c := config.GetGlobalConfig()
+	action := c.OAuthMetadataAction
+	input := getInputData(r)
+	input.IssuerPath = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, oauthMetadataPath), "/")
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		metadataRespond(w, input, action.Respond.Parameters)
+	case "mirror":
+		metadataRespond(w, input, c.DiscoveryAction.Respond.Parameters)
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// metadataRespond responds with the metadata parameters.
+func metadataRespond(w http.ResponseWriter, input *sessionmgmt.RequestInput, parameters []config.Parameter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	jsonResponse(w, input, parameters)
+}
@@ -34,14 +34,38 @@ func TestDiscoveryHandler(t *testing.T) {
 			title:    "Default Config",
 			wantCode: 200,
 			wantResults: map[string]any{
-				"issuer":                                "https://idp.idp",
-				"authorization_endpoint":                "https://idp.idp/oauth2/auth",
-				"token_endpoint":                        "https://idp.idp/oauth2/token",
-				"userinfo_endpoint":                     "https://idp.idp/oauth2/userinfo",
-				"jwks_uri":                              "https://idp.idp/.well-known/jwks.json",
-				"id_token_signing_alg_values_supported": []any{"RS256", "RS512", "ES256"},
-				"subject_types_supported":               []any{"public"},
-				"response_types_supported":              []any{"code", "code id_token", "id_token", "token id_token", "token", "token id_token code"},
+				"issuer":                                           "https://idp.idp",
+				"authorization_endpoint":                           "https://idp.idp/oauth2/auth",
+				"token_endpoint":                                   "https://idp.idp/oauth2/token",
+				"userinfo_endpoint":                                "https://idp.idp/oauth2/userinfo",
+				"device_authorization_endpoint":                    "https://idp.idp/oauth2/device_authorization",
+				"backchannel_authentication_endpoint":              "https://idp.idp/oauth2/bc-authorize",
+				"backchannel_token_delivery_modes_supported":       []any{"poll", "ping", "push"},
+				"pushed_authorization_request_endpoint":            "https://idp.idp/oauth2/par",
+				"introspection_endpoint":                           "https://idp.idp/oauth2/introspect",
+				"revocation_endpoint":                              "https://idp.idp/oauth2/revoke",
+				"end_session_endpoint":                             "https://idp.idp/oauth2/logout",
+				"registration_endpoint":                            "https://idp.idp/oauth2/register",
+				"token_endpoint_auth_methods_supported":            []any{"client_secret_basic", "client_secret_post", "client_secret_jwt", "private_key_jwt", "tls_client_auth", "self_signed_tls_client_auth", "none"},
+				"tls_client_certificate_bound_access_tokens":       true,
+				"dpop_signing_alg_values_supported":                []any{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+				"token_endpoint_auth_signing_alg_values_supported": []any{"HS256", "RS256", "RS512", "ES256"},
+				"frontchannel_logout_supported":                    true,
+				"frontchannel_logout_session_supported":            true,
+				"backchannel_logout_supported":                     true,
+				"backchannel_logout_session_supported":             true,
+				"jwks_uri":                                         "https://idp.idp/.well-known/jwks.json",
+				"id_token_signing_alg_values_supported":            []any{"RS256", "RS512", "ES256"},
+				"subject_types_supported":                          []any{"public"},
+				"response_types_supported":                         []any{"code", "code id_token", "id_token", "token id_token", "token", "token id_token code"},
+				"response_modes_supported":                         []any{"query", "fragment", "form_post", "web_message", "jwt", "query.jwt", "fragment.jwt", "form_post.jwt"},
+				"authorization_signing_alg_values_supported":       []any{"RS256", "RS512", "ES256"},
+				"code_challenge_methods_supported":                 []any{"S256", "plain"},
+				"grant_types_supported": []any{
+					"authorization_code", "implicit", "refresh_token", "client_credentials",
+					"urn:ietf:params:oauth:grant-type:device_code", "urn:ietf:params:oauth:grant-type:token-exchange",
+					"urn:ietf:params:oauth:grant-type:jwt-bearer", "urn:openid:params:grant-type:ciba",
+				},
 			},
 		},
 		{
@@ -115,3 +139,112 @@ func TestDiscoveryHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestOAuthMetadataHandler(t *testing.T) {
+	cases := []struct {
+		title      string
+		action     config.OAuthMetadataAction
+		path       string
+		wantCode   int
+		wantIssuer any
+		wantKey    string
+	}{
+		{
+			title:      "Default Config",
+			action:     config.DefaultConfig.OAuthMetadataAction,
+			path:       "/.well-known/oauth-authorization-server",
+			wantCode:   200,
+			wantIssuer: "https://idp.idp",
+			wantKey:    "code_challenge_methods_supported",
+		},
+		{
+			title:      "Issuer path",
+			action:     config.DefaultConfig.OAuthMetadataAction,
+			path:       "/.well-known/oauth-authorization-server/tenant/one",
+			wantCode:   200,
+			wantIssuer: "https://idp.idp/tenant/one",
+			wantKey:    "grant_types_supported",
+		},
+		{
+			title:      "Mirror",
+			action:     config.OAuthMetadataAction{Action: "mirror"},
+			path:       "/.well-known/oauth-authorization-server/tenant",
+			wantCode:   200,
+			wantIssuer: "https://idp.idp",
+			wantKey:    "userinfo_endpoint",
+		},
+		{
+			title:    "Error response",
+			action:   config.OAuthMetadataAction{Action: "error", Error: config.Error{ErrorCode: 404, ErrorContent: "not found"}},
+			path:     "/.well-known/oauth-authorization-server",
+			wantCode: 404,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.OAuthMetadataAction = tc.action
+			config.SetGlobalConfig(&c)
+
+			req, err := http.NewRequest("GET", "https://idp.idp"+tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(oauthMetadataHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("oauthMetadataHandler() returned unexpected code %d, expected %d", rr.Code, tc.wantCode)
+			}
+			if rr.Code != 200 {
+				return
+			}
+
+			var gotResults map[string]any
+			if err = json.Unmarshal(rr.Body.Bytes(), &gotResults); err != nil {
+				t.Fatalf("Failed to parse json data returned from oauthMetadataHandler() %v", err)
+			}
+			if gotResults["issuer"] != tc.wantIssuer {
+				t.Errorf("oauthMetadataHandler() returned issuer %v, expected %v", gotResults["issuer"], tc.wantIssuer)
+			}
+			if _, ok := gotResults[tc.wantKey]; !ok {
+				t.Errorf("oauthMetadataHandler() did not return %s: %v", tc.wantKey, gotResults)
+			}
+		})
+	}
+}
+
+func TestOAuthMetadataMatchesDiscovery(t *testing.T) {
+	config.SetGlobalConfig(&config.DefaultConfig)
+
+	get := func(handler http.HandlerFunc, path string) map[string]any {
+		req, err := http.NewRequest("GET", "https://idp.idp"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		var results map[string]any
+		if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+			t.Fatalf("Failed to parse json data returned from %s: %v", path, err)
+		}
+		return results
+	}
+
+	discovery := get(discHandler, "/.well-known/openid-configuration")
+	metadata := get(oauthMetadataHandler, "/.well-known/oauth-authorization-server")
+	if got, want := metadata["response_types_supported"], []any{"code", "token"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("metadata response_types_supported is %v, expected %v", got, want)
+	}
+	for key, want := range metadata {
+		// OAuth clients have no use for the OIDC response types.
+		if key == "response_types_supported" {
+			continue
+		}
+		if !reflect.DeepEqual(discovery[key], want) {
+			t.Errorf("discovery %q is %v, metadata has %v", key, discovery[key], want)
+		}
+	}
+}
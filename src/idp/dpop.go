@@ -0,0 +1,198 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"crypto/sha256"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dpopProofType is the typ header of DPoP proofs.
+const dpopProofType = "dpop+jwt"
+
+// errDPoPNonce is returned for DPoP proofs without a valid server nonce.
+var errDPoPNonce = errors.New("DPoP proof is missing a valid nonce")
+
+// errDPoPBinding is returned when a DPoP-bound token is used without its key.
+var errDPoPBinding = errors.New("token is bound to another DPoP key")
+
+// decodeDPoPProof decodes a DPoP proof without verification, returning nil if
+// it can't be decoded. The thumbprint is only set for a public jwk header.
+func decodeDPoPProof(raw string) *sessionmgmt.DPoPProof {
+	proof := decodeJWT(raw)
+	if proof == nil {
+		return nil
+	}
+
+	dpop := &sessionmgmt.DPoPProof{JWT: *proof}
+	if key, ok := proof.Header["jwk"]; ok {
+		if b, err := json.Marshal(key); err == nil {
+			dpop.JKT, _ = keys.JWKThumbprint(string(b))
+		}
+	}
+	return dpop
+}
+
+// checkDPoPProof validates the DPoP proof of the request per RFC 9449 Section 4.3.
+// The ath claim is checked when an access token is given.
+func checkDPoPProof(c *config.DPoPConfig, input *sessionmgmt.RequestInput, accessToken string) error {
+	proofs := input.Headers.Values("DPoP")
+	if len(proofs) == 0 {
+		return errors.New("missing DPoP proof")
+	} else if len(proofs) > 1 {
+		return errors.New("multiple DPoP proofs")
+	}
+
+	proof := input.DPoP
+	if proof == nil {
+		return errors.New("malformed DPoP proof")
+	}
+
+	if typ, _ := proof.Header["typ"].(string); typ != dpopProofType {
+		return fmt.Errorf("DPoP proof typ is %q, expected %s", typ, dpopProofType)
+	}
+
+	if alg, _ := proof.Header["alg"].(string); strings.HasPrefix(alg, "HS") {
+		return errors.New("DPoP proof is not signed with an asymmetric algorithm")
+	}
+
+	if proof.JKT == "" {
+		return errors.New("DPoP proof has no public jwk header")
+	}
+
+	jwks, err := json.Marshal(map[string]any{"keys": []any{proof.Header["jwk"]}})
+	if err != nil {
+		return err
+	}
+	if err := keys.VerifyWithKeySet(proof.Raw, string(jwks)); err != nil {
+		return fmt.Errorf("DPoP proof signature is invalid: %v", err)
+	}
+
+	if htm, _ := proof.Claims["htm"].(string); htm != input.HTTPMethod {
+		return fmt.Errorf("DPoP proof htm is %q, expected %s", htm, input.HTTPMethod)
+	}
+
+	htu, _ := proof.Claims["htu"].(string)
+	htu, _, _ = strings.Cut(htu, "?")
+	htu, _, _ = strings.Cut(htu, "#")
+	if expected := "https://" + input.Domain + input.Path; htu != expected {
+		return fmt.Errorf("DPoP proof htu is %q, expected %s", htu, expected)
+	}
+
+	lifetime := time.Duration(c.ProofLifetime) * time.Second
+	iat, ok := proof.Claims["iat"].(float64)
+	if !ok || math.Abs(input.Time.Sub(time.Unix(int64(iat), 0)).Seconds()) > lifetime.Seconds() {
+		return errors.New("DPoP proof iat is outside the accepted window")
+	}
+
+	jti, _ := proof.Claims["jti"].(string)
+	if jti == "" {
+		return errors.New("DPoP proof has no jti")
+	}
+	if err := sessionmgmt.UseDPoPJTI(jti, input.Time, time.Unix(int64(iat), 0).Add(lifetime)); err != nil {
+		return err
+	}
+
+	if c.RequireNonce {
+		nonce, _ := proof.Claims["nonce"].(string)
+		if err := sessionmgmt.CheckDPoPNonce(nonce, input.Time); err != nil {
+			return errDPoPNonce
+		}
+	}
+
+	if accessToken != "" {
+		hash := sha256.Sum256([]byte(accessToken))
+		if ath, _ := proof.Claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(hash[:]) {
+			return errors.New("DPoP proof ath does not match the access token")
+		}
+	}
+	return nil
+}
+
+// checkDPoPBinding checks a DPoP token request to a resource, or the use of a
+// DPoP-bound token. Bound tokens must be sent with the DPoP scheme and a proof
+// with the bound key, unless configured to ignore the binding.
+func checkDPoPBinding(c *config.DPoPConfig, input *sessionmgmt.RequestInput, accessToken string, isDPoP bool) error {
+	bound := input.Token != nil && input.Token.JKT != ""
+	if !isDPoP {
+		if bound && !c.IgnoreBinding {
+			return errDPoPBinding
+		}
+		return nil
+	}
+
+	if err := checkDPoPProof(c, input, accessToken); err != nil {
+		return err
+	}
+
+	if bound && input.Token.JKT != input.DPoP.JKT && !c.IgnoreBinding {
+		return errDPoPBinding
+	}
+	return nil
+}
+
+// writeDPoPNonce sets the DPoP-Nonce header to a new nonce. When configured to
+// misbehave the nonce is already stale.
+func writeDPoPNonce(w http.ResponseWriter, c *config.DPoPConfig, now time.Time) {
+	nonce, err := generateBase64ID(16)
+	if err != nil {
+		return
+	}
+
+	expiresAt := now.Add(time.Duration(c.NonceLifetime) * time.Second)
+	if c.StaleNonce {
+		expiresAt = now
+	}
+	sessionmgmt.AddDPoPNonce(nonce, expiresAt)
+	w.Header().Set("DPoP-Nonce", nonce)
+}
+
+// dpopTokenError responds to a token request with a rejected DPoP proof.
+// A use_dpop_nonce error comes with a new nonce.
+func dpopTokenError(w http.ResponseWriter, c *config.DPoPConfig, input *sessionmgmt.RequestInput, err error) {
+	if errors.Is(err, errDPoPNonce) {
+		writeDPoPNonce(w, c, input.Time)
+		oauthErrorResponse(w, http.StatusBadRequest, "use_dpop_nonce", err.Error())
+		return
+	}
+	oauthErrorResponse(w, http.StatusBadRequest, "invalid_dpop_proof", err.Error())
+}
+
+// dpopResourceError responds to a resource request with a rejected DPoP proof
+// or binding, with a DPoP WWW-Authenticate challenge. The challenge has a fixed
+// error_description as the error can contain quotes.
+func dpopResourceError(w http.ResponseWriter, c *config.DPoPConfig, input *sessionmgmt.RequestInput, err error) {
+	code, desc := "invalid_dpop_proof", "DPoP proof is invalid"
+	switch {
+	case errors.Is(err, errDPoPNonce):
+		code, desc = "use_dpop_nonce", "DPoP proof requires a nonce"
+		writeDPoPNonce(w, c, input.Time)
+	case errors.Is(err, errDPoPBinding):
+		code, desc = "invalid_token", "DPoP proof does not match the access token"
+	}
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`DPoP error="%s", error_description="%s"`, code, desc))
+	oauthErrorResponse(w, http.StatusUnauthorized, code, err.Error())
+}
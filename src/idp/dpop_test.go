@@ -0,0 +1,338 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// newDPoPKey creates a client DPoP key.
+func newDPoPKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// dpopThumbprint returns the JWK thumbprint of the DPoP key.
+func dpopThumbprint(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	pub, err := jwk.New(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(pub)
+	jkt, err := keys.JWKThumbprint(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jkt
+}
+
+// dpopProof creates a DPoP proof for the method and URI. Claims replace the
+// defaults, a nil claim value removes it.
+func dpopProof(t *testing.T, key *ecdsa.PrivateKey, typ string, claims map[string]any) string {
+	t.Helper()
+	jti, err := generateBase64ID(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := map[string]any{"htm": "POST", "htu": "https://idp.idp/oauth2/token", "iat": time.Now().Unix(), "jti": jti}
+	for id, val := range claims {
+		defaults[id] = val
+	}
+
+	token := jwt.New()
+	for id, val := range defaults {
+		if val != nil {
+			token.Set(id, val)
+		}
+	}
+
+	pub, err := jwk.New(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := jws.NewHeaders()
+	headers.Set(jws.TypeKey, typ)
+	headers.Set(jws.JWKKey, pub)
+
+	signed, err := jwt.Sign(token, jwa.ES256, key, jwt.WithHeaders(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+// postDPoPTokenRequest requests a client credentials token with the DPoP proof.
+func postDPoPTokenRequest(t *testing.T, proof string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"dpopclient"}}
+	req, err := http.NewRequest("POST", "https://idp.idp/oauth2/token", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if proof != "" {
+		req.Header.Set("DPoP", proof)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(tokenHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestDPoPTokenEndpoint(t *testing.T) {
+	key := newDPoPKey(t)
+	replayed := dpopProof(t, key, dpopProofType, nil)
+
+	cases := []struct {
+		title     string
+		dpop      config.DPoPConfig
+		proof     string
+		wantCode  int
+		wantError string
+		wantType  string
+	}{
+		{title: "No proof", dpop: config.DefaultConfig.DPoPConfig, wantCode: http.StatusOK, wantType: "Bearer"},
+		{title: "Valid proof", dpop: config.DefaultConfig.DPoPConfig, proof: replayed, wantCode: http.StatusOK, wantType: "DPoP"},
+		{title: "Replayed proof", dpop: config.DefaultConfig.DPoPConfig, proof: replayed, wantCode: http.StatusBadRequest, wantError: "invalid_dpop_proof"},
+		{
+			title:    "Bearer issued for proof",
+			dpop:     config.DPoPConfig{ProofLifetime: 300, IssueBearer: true},
+			proof:    dpopProof(t, key, dpopProofType, nil),
+			wantCode: http.StatusOK,
+			wantType: "Bearer",
+		},
+		{
+			title:     "Missing required proof",
+			dpop:      config.DPoPConfig{ProofLifetime: 300, RequireProof: true},
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_dpop_proof",
+		},
+		{
+			title:     "Wrong typ",
+			dpop:      config.DefaultConfig.DPoPConfig,
+			proof:     dpopProof(t, key, "JWT", nil),
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_dpop_proof",
+		},
+		{
+			title:     "Wrong htm",
+			dpop:      config.DefaultConfig.DPoPConfig,
+			proof:     dpopProof(t, key, dpopProofType, map[string]any{"htm": "GET"}),
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_dpop_proof",
+		},
+		{
+			title:     "Wrong htu",
+			dpop:      config.DefaultConfig.DPoPConfig,
+			proof:     dpopProof(t, key, dpopProofType, map[string]any{"htu": "https://idp.idp/oauth2/userinfo"}),
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_dpop_proof",
+		},
+		{
+			title:     "Old iat",
+			dpop:      config.DefaultConfig.DPoPConfig,
+			proof:     dpopProof(t, key, dpopProofType, map[string]any{"iat": time.Now().Add(-time.Hour).Unix()}),
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_dpop_proof",
+		},
+		{
+			title:     "Missing jti",
+			dpop:      config.DefaultConfig.DPoPConfig,
+			proof:     dpopProof(t, key, dpopProofType, map[string]any{"jti": nil}),
+			wantCode:  http.StatusBadRequest,
+			wantError: "invalid_dpop_proof",
+		},
+		{
+			title:     "Missing nonce",
+			dpop:      config.DPoPConfig{ProofLifetime: 300, NonceLifetime: 300, RequireNonce: true},
+			proof:     dpopProof(t, key, dpopProofType, nil),
+			wantCode:  http.StatusBadRequest,
+			wantError: "use_dpop_nonce",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.DPoPConfig = tc.dpop
+			config.SetGlobalConfig(&c)
+
+			rr := postDPoPTokenRequest(t, tc.proof)
+			var content map[string]any
+			json.Unmarshal(rr.Body.Bytes(), &content)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("tokenHandler() returned %d rather than expected %d: %v", rr.Code, tc.wantCode, content)
+			}
+
+			if tc.wantError != "" {
+				if content["error"] != tc.wantError {
+					t.Errorf("tokenHandler() returned error %v, expected %s", content["error"], tc.wantError)
+				}
+				if gotNonce := rr.Header().Get("DPoP-Nonce") != ""; gotNonce != (tc.wantError == "use_dpop_nonce") {
+					t.Errorf("tokenHandler() returned DPoP-Nonce %q", rr.Header().Get("DPoP-Nonce"))
+				}
+				return
+			}
+
+			if content["token_type"] != tc.wantType {
+				t.Errorf("tokenHandler() returned token_type %v, expected %s", content["token_type"], tc.wantType)
+			}
+
+			token, err := sessionmgmt.GetToken(content["access_token"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantJKT := ""
+			if tc.wantType == "DPoP" {
+				wantJKT = dpopThumbprint(t, key)
+			}
+			if token.JKT != wantJKT {
+				t.Errorf("access token is bound to %q, expected %q", token.JKT, wantJKT)
+			}
+		})
+	}
+}
+
+func TestDPoPNonce(t *testing.T) {
+	key := newDPoPKey(t)
+	cases := []struct {
+		title    string
+		stale    bool
+		wantCode int
+	}{
+		{title: "Fresh nonce", wantCode: http.StatusOK},
+		{title: "Stale nonce", stale: true, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.DPoPConfig = config.DPoPConfig{ProofLifetime: 300, NonceLifetime: 300, RequireNonce: true, StaleNonce: tc.stale}
+			config.SetGlobalConfig(&c)
+
+			challenge := postDPoPTokenRequest(t, dpopProof(t, key, dpopProofType, nil))
+			nonce := challenge.Header().Get("DPoP-Nonce")
+			if challenge.Code != http.StatusBadRequest || nonce == "" {
+				t.Fatalf("tokenHandler() returned %d with nonce %q rather than a nonce challenge", challenge.Code, nonce)
+			}
+
+			rr := postDPoPTokenRequest(t, dpopProof(t, key, dpopProofType, map[string]any{"nonce": nonce}))
+			if rr.Code != tc.wantCode {
+				t.Errorf("tokenHandler() returned %d with the nonce rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDPoPUserInfo(t *testing.T) {
+	key := newDPoPKey(t)
+	otherKey := newDPoPKey(t)
+
+	config.SetGlobalConfig(&config.DefaultConfig)
+	issued := postDPoPTokenRequest(t, dpopProof(t, key, dpopProofType, nil))
+	var content map[string]any
+	json.Unmarshal(issued.Body.Bytes(), &content)
+	accessToken, _ := content["access_token"].(string)
+	if accessToken == "" {
+		t.Fatalf("tokenHandler() did not issue an access token: %v", content)
+	}
+
+	hash := sha256.Sum256([]byte(accessToken))
+	ath := base64.RawURLEncoding.EncodeToString(hash[:])
+	userinfo := map[string]any{"htm": "GET", "htu": "https://idp.idp/oauth2/userinfo", "ath": ath}
+
+	cases := []struct {
+		title         string
+		scheme        string
+		proof         string
+		ignoreBinding bool
+		wantCode      int
+		wantError     string
+	}{
+		{title: "Bound proof", scheme: "DPoP", proof: dpopProof(t, key, dpopProofType, userinfo), wantCode: http.StatusOK},
+		{title: "Bearer scheme", scheme: "Bearer", wantCode: http.StatusUnauthorized, wantError: "invalid_token"},
+		{title: "Bearer scheme accepted", scheme: "Bearer", ignoreBinding: true, wantCode: http.StatusOK},
+		{title: "Other key", scheme: "DPoP", proof: dpopProof(t, otherKey, dpopProofType, userinfo), wantCode: http.StatusUnauthorized, wantError: "invalid_token"},
+		{title: "Other key accepted", scheme: "DPoP", proof: dpopProof(t, otherKey, dpopProofType, userinfo), ignoreBinding: true, wantCode: http.StatusOK},
+		{
+			title:     "Missing ath",
+			scheme:    "DPoP",
+			proof:     dpopProof(t, key, dpopProofType, map[string]any{"htm": "GET", "htu": "https://idp.idp/oauth2/userinfo"}),
+			wantCode:  http.StatusUnauthorized,
+			wantError: "invalid_dpop_proof",
+		},
+		{title: "Missing proof", scheme: "DPoP", wantCode: http.StatusUnauthorized, wantError: "invalid_dpop_proof"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.DPoPConfig.IgnoreBinding = tc.ignoreBinding
+			config.SetGlobalConfig(&c)
+
+			req, err := http.NewRequest("GET", "https://idp.idp/oauth2/userinfo", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", tc.scheme+" "+accessToken)
+			if tc.proof != "" {
+				req.Header.Set("DPoP", tc.proof)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(userInfoHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("userInfoHandler() returned %d rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+
+			if tc.wantError != "" {
+				want := `DPoP error="` + tc.wantError + `"`
+				got := rr.Header().Get("WWW-Authenticate")
+				if !strings.HasPrefix(got, want) {
+					t.Errorf("userInfoHandler() returned WWW-Authenticate %q, expected %s", got, want)
+				}
+				if strings.Count(got, `"`) != 4 {
+					t.Errorf("userInfoHandler() returned malformed WWW-Authenticate %q", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// formPostTemplate is an auto-submitting form posting the authorization response to the client.
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<html>
+<head><title>Submit This Form</title></head>
+<body>
+<form method='POST' action='{{.Target}}'>
+{{range $id, $vals := .Params}}{{range $vals}}<input type='hidden' name='{{$id}}' value='{{.}}'>
+{{end}}{{end}}<noscript><button type='submit'>Continue</button></noscript>
+</form>
+<script nonce='{{.Nonce}}'>document.forms[0].submit();</script>
+</body>
+</html>`))
+
+// writeFormPost renders a page that posts the parameters to the target. Only the
+// inline submit script is allowed to run, and only posts to the target origin.
+func writeFormPost(w http.ResponseWriter, target string, params url.Values) {
+	nonce, err := getNonce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	csp := fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'", nonce)
+	if origin := getOrigin(target); origin != "" {
+		csp += "; form-action " + origin
+	}
+
+	w.Header().Set("Content-Security-Policy", csp)
+	formPostTemplate.Execute(w, struct {
+		Target string
+		Params url.Values
+		Nonce  string
+	}{target, params, nonce})
+}
+
+// getOrigin returns the origin of a URI, or an empty string if it has no scheme.
+func getOrigin(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return ""
+	}
+
+	if u.Host == "" {
+		return u.Scheme + ":"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -34,9 +34,22 @@ func InitHandlers(staticTemplates *template.Template, staticDir string) error {
 	http.HandleFunc("/config", configHandler)
 	http.HandleFunc("/configschema", configSchemaHandler)
 	http.HandleFunc("/.well-known/openid-configuration", respLogHandler(discHandler))
+	http.HandleFunc(oauthMetadataPath, respLogHandler(oauthMetadataHandler))
+	http.HandleFunc(oauthMetadataPath+"/", respLogHandler(oauthMetadataHandler))
+	http.HandleFunc("/.well-known/webfinger", respLogHandler(webFingerHandler))
 	http.HandleFunc("/.well-known/jwks.json", respLogHandler(keyHandler))
 	http.HandleFunc("/oauth2/auth", respLogHandler(authHandler))
 	http.HandleFunc("/oauth2/token", respLogHandler(tokenHandler))
 	http.HandleFunc("/oauth2/userinfo", respLogHandler(userInfoHandler))
+	http.HandleFunc("/oauth2/device_authorization", respLogHandler(deviceAuthHandler))
+	http.HandleFunc("/oauth2/device", respLogHandler(deviceVerifyHandler))
+	http.HandleFunc("/oauth2/bc-authorize", respLogHandler(cibaHandler))
+	http.HandleFunc("/oauth2/bc-approve", respLogHandler(cibaApproveHandler))
+	http.HandleFunc("/oauth2/par", respLogHandler(parHandler))
+	http.HandleFunc("/oauth2/introspect", respLogHandler(introspectHandler))
+	http.HandleFunc("/oauth2/revoke", respLogHandler(revokeHandler))
+	http.HandleFunc("/oauth2/logout", respLogHandler(logoutHandler))
+	http.HandleFunc("/oauth2/backchannel-logout", respLogHandler(backchannelLogoutHandler))
+	http.HandleFunc("/oauth2/register", respLogHandler(registrationHandler))
 	return nil
 }
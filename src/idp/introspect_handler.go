@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// introspectionJWTType is the RFC 9701 media type of JWT introspection responses.
+const introspectionJWTType = "application/token-introspection+jwt"
+
+// introspectHandler takes action for the Token Introspection endpoint based on config.
+func introspectHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().IntrospectionAction
+	input := getInputData(r)
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		introspectRespond(w, r, input)
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// introspectRespond responds with the configured parameters for active tokens,
+// and {"active": false} otherwise. The response is a signed JWT if configured or
+// requested with the Accept header.
+func introspectRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput) {
+	c := config.GetGlobalConfig()
+	respond := c.IntrospectionAction.Respond
+	if input.FormParams.Get("token") == "" {
+		oauthErrorResponse(w, http.StatusBadRequest, "invalid_request", "missing token")
+		return
+	}
+
+	content := map[string]any{"active": false}
+	if isTokenActive(respond.ActiveMode, input) {
+		var err error
+		content, err = getJSONContent(input, respond.Parameters)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, ok := content["active"]; !ok {
+			content["active"] = true
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if respond.ResponseFormat == "jwt" || (respond.ResponseFormat != "json" && strings.Contains(r.Header.Get("Accept"), introspectionJWTType)) {
+		token, err := config.GenerateIntrospectionToken(input, c, content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", introspectionJWTType)
+		fmt.Fprint(w, token)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, content)
+}
+
+// isTokenActive checks whether the presented token was issued and is not revoked,
+// rotated or expired. The mode can deliberately treat revoked or unknown tokens as active.
+func isTokenActive(mode string, input *sessionmgmt.RequestInput) bool {
+	if mode == "accept_any" {
+		return true
+	}
+
+	token := input.Token
+	if token == nil {
+		return false
+	}
+
+	if (token.Revoked || token.Rotated) && mode != "accept_revoked" {
+		return false
+	}
+
+	return token.ExpiresAt.IsZero() || input.Time.Before(token.ExpiresAt)
+}
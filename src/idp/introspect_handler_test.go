@@ -0,0 +1,156 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// postIntrospectRequest calls the introspection endpoint with the token.
+func postIntrospectRequest(t *testing.T, token string, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"client_id": {"gateway"}}
+	if token != "" {
+		form.Set("token", token)
+	}
+
+	req, err := http.NewRequest("POST", "/oauth2/introspect", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(introspectHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIntrospectHandler(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	config.SetGlobalConfig(&config.DefaultConfig)
+	code, issued := postTokenRequest(t, url.Values{"grant_type": {"client_credentials"}, "client_id": {"apiclient"}, "scope": {"read write"}})
+	if code != http.StatusOK {
+		t.Fatalf("tokenHandler() returned %d: %v", code, issued)
+	}
+	accessToken := issued["access_token"].(string)
+
+	sessionmgmt.AddToken(sessionmgmt.Token{Value: "revokedtoken", Type: "access_token", Revoked: true})
+	sessionmgmt.AddToken(sessionmgmt.Token{Value: "expiredtoken", Type: "access_token", ExpiresAt: time.Now().Add(-time.Minute)})
+
+	cases := []struct {
+		title      string
+		activeMode string
+		token      string
+		wantActive bool
+	}{
+		{title: "Issued token", activeMode: "issued", token: accessToken, wantActive: true},
+		{title: "Refresh token", activeMode: "issued", token: issued["refresh_token"].(string), wantActive: true},
+		{title: "Unknown token", activeMode: "issued", token: "unknown"},
+		{title: "Revoked token", activeMode: "issued", token: "revokedtoken"},
+		{title: "Expired token", activeMode: "issued", token: "expiredtoken"},
+		{title: "Accept revoked", activeMode: "accept_revoked", token: "revokedtoken", wantActive: true},
+		{title: "Accept any", activeMode: "accept_any", token: "unknown", wantActive: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.IntrospectionAction.Respond.ActiveMode = tc.activeMode
+			config.SetGlobalConfig(&c)
+
+			rr := postIntrospectRequest(t, tc.token, "")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("introspectHandler() returned %d rather than expected 200", rr.Code)
+			}
+
+			var results map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+				t.Fatalf("Failed to parse json data returned from introspectHandler() %v", err)
+			}
+
+			if results["active"] != tc.wantActive {
+				t.Errorf("introspectHandler() returned active %v, expected %v", results["active"], tc.wantActive)
+			}
+			if !tc.wantActive && len(results) != 1 {
+				t.Errorf("introspectHandler() returned more than active for an inactive token: %v", results)
+			}
+		})
+	}
+
+	t.Run("Issued token details", func(t *testing.T) {
+		config.SetGlobalConfig(&config.DefaultConfig)
+		var results map[string]any
+		if err := json.Unmarshal(postIntrospectRequest(t, accessToken, "").Body.Bytes(), &results); err != nil {
+			t.Fatal(err)
+		}
+
+		if results["scope"] != "read write" || results["client_id"] != "apiclient" || results["token_type"] != "Bearer" {
+			t.Errorf("introspectHandler() returned unexpected details %v", results)
+		}
+		if exp, ok := results["exp"].(float64); !ok || time.Unix(int64(exp), 0).Before(time.Now().Add(59*time.Minute)) {
+			t.Errorf("introspectHandler() returned unexpected exp %v", results["exp"])
+		}
+	})
+
+	t.Run("JWT response", func(t *testing.T) {
+		config.SetGlobalConfig(&config.DefaultConfig)
+		rr := postIntrospectRequest(t, accessToken, introspectionJWTType)
+		if got := rr.Header().Get("Content-Type"); got != introspectionJWTType {
+			t.Fatalf("introspectHandler() returned content type %q", got)
+		}
+
+		pubKey, err := keys.GetKey("RSA", false).Jwk.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := jwt.ParseString(rr.Body.String(), jwt.WithVerify(jwa.RS256, pubKey))
+		if err != nil {
+			t.Fatalf("introspectHandler() returned an invalid JWT: %v", err)
+		}
+
+		if aud := token.Audience(); len(aud) != 1 || aud[0] != "gateway" {
+			t.Errorf("introspectHandler() returned aud %v", aud)
+		}
+		claim, _ := token.Get("token_introspection")
+		if introspection, ok := claim.(map[string]any); !ok || introspection["active"] != true {
+			t.Errorf("introspectHandler() returned token_introspection %v", claim)
+		}
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		config.SetGlobalConfig(&config.DefaultConfig)
+		if rr := postIntrospectRequest(t, "", ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("introspectHandler() returned %d rather than expected 400", rr.Code)
+		}
+	})
+}
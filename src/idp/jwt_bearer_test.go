@@ -0,0 +1,105 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+func TestJWTBearerGrant(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := keys.GetJSONKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makeAssertion := func(alg string, wrongKey bool, aud string, exp time.Time) string {
+		token := jwt.New()
+		token.Set("iss", "serviceaccount")
+		token.Set("sub", "serviceaccount")
+		token.Set("aud", aud)
+		token.Set("exp", exp.Unix())
+		signed, err := keys.SignToken(alg, token, wrongKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	valid := makeAssertion("RS256", false, "https://idp.idp/oauth2/token", time.Now().Add(time.Hour))
+	wrongKey := makeAssertion("RS256", true, "https://idp.idp/oauth2/token", time.Now().Add(time.Hour))
+	expired := makeAssertion("RS256", false, "https://idp.idp/oauth2/token", time.Now().Add(-time.Hour))
+	wrongAudience := makeAssertion("RS256", false, "https://other.idp", time.Now().Add(time.Hour))
+	unsigned := makeAssertion("none", false, "https://idp.idp/oauth2/token", time.Now().Add(time.Hour))
+
+	strict := config.JWTBearerConfig{
+		VerifySignature: true,
+		JWKS:            jwks,
+		Audience:        []string{"https://idp.idp/oauth2/token"},
+	}
+
+	cases := []struct {
+		title     string
+		config    config.JWTBearerConfig
+		assertion string
+		wantCode  int
+	}{
+		{title: "Valid", config: strict, assertion: valid, wantCode: 200},
+		{title: "Missing", config: strict, wantCode: 400},
+		{title: "Wrong key", config: strict, assertion: wrongKey, wantCode: 400},
+		{title: "Wrong key without verification", config: config.JWTBearerConfig{Audience: strict.Audience}, assertion: wrongKey, wantCode: 200},
+		{title: "Expired", config: strict, assertion: expired, wantCode: 400},
+		{title: "Accept expired", config: config.JWTBearerConfig{VerifySignature: true, JWKS: jwks, Audience: strict.Audience, AcceptExpired: true}, assertion: expired, wantCode: 200},
+		{title: "Wrong audience", config: strict, assertion: wrongAudience, wantCode: 400},
+		{title: "Accept wrong audience", config: config.JWTBearerConfig{VerifySignature: true, JWKS: jwks, AcceptWrongAudience: true}, assertion: wrongAudience, wantCode: 200},
+		{title: "Unsigned", config: strict, assertion: unsigned, wantCode: 400},
+		{title: "Accept unsigned", config: config.JWTBearerConfig{VerifySignature: true, JWKS: jwks, Audience: strict.Audience, AcceptUnsigned: true}, assertion: unsigned, wantCode: 200},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			config.SetGlobalConfig(&config.Config{
+				TokenAction: config.TokenAction{
+					Action: "respond",
+					Respond: config.TokenRespond{
+						Parameters: []config.Parameter{
+							{ID: "sub", Action: "set", Values: []string{"{{index .Assertion.Claims \"sub\"}}"}, JSONType: "string"},
+							{ID: "alg", Action: "set", Values: []string{"{{index .Assertion.Header \"alg\"}}"}, JSONType: "string"},
+						},
+					},
+					JWTBearer: tc.config,
+				},
+			})
+
+			gotCode, results := postTokenRequest(t, url.Values{"grant_type": {jwtBearerGrantType}, "assertion": {tc.assertion}})
+			if gotCode != tc.wantCode {
+				t.Fatalf("tokenHandler() returned %d rather than expected %d: %v", gotCode, tc.wantCode, results)
+			}
+
+			if gotCode == 200 && results["sub"] != "serviceaccount" {
+				t.Errorf("tokenHandler() returned sub %v, expected serviceaccount", results["sub"])
+			}
+		})
+	}
+}
@@ -188,6 +188,15 @@ func getResponseEntry(resp *http.Response) *ResponseEntry {
 	}
 }
 
+// indentJSON pretty-prints a value as JSON for the log view.
+func indentJSON(v any) string {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
 // writeRequestLog outputs the RequestLog as an HTML Table.
 func writeRequestLog(w http.ResponseWriter) {
 	logMutex.Lock()
@@ -224,6 +233,21 @@ func writeRequestLog(w http.ResponseWriter) {
 		writeRow(w, "Action Taken:", req.action)
 		writeParams(w, req.input)
 
+		if req.input.RequestObject != nil {
+			writeWideRow(w, "Request Object:", req.input.RequestObject.Raw)
+			writeWideRow(w, "Request Object Header:", indentJSON(req.input.RequestObject.Header))
+			writeWideRow(w, "Request Object Claims:", indentJSON(req.input.RequestObject.Claims))
+		}
+
+		if req.input.Registration != nil {
+			writeWideRow(w, "Client Metadata:", indentJSON(req.input.Registration.Metadata))
+		}
+
+		if len(req.input.ClientCertificates) != 0 {
+			writeRow(w, "Client Certificate:", req.input.ClientCertificates[0].Subject.String())
+			writeRow(w, "Certificate Thumbprint:", req.input.CertificateThumbprint)
+		}
+
 		if req.input.Session != nil {
 			writeRow(w, "Session Code:", req.input.Session.Code)
 			writeRow(w, "Session ClientID:", req.input.Session.ClientID)
@@ -231,6 +255,12 @@ func writeRequestLog(w http.ResponseWriter) {
 			writeRow(w, "Session Nonce:", req.input.Session.Nonce)
 			writeRow(w, "Session Challenge:", req.input.Session.CodeChallenge)
 			writeRow(w, "Session Challenge Method:", req.input.Session.CodeChallengeMethod)
+			if len(req.input.Session.Resources) != 0 {
+				writeRow(w, "Session Resources:", strings.Join(req.input.Session.Resources, " "))
+			}
+			if len(req.input.Session.AuthorizationDetails) != 0 {
+				writeWideRow(w, "Session Authorization Details:", indentJSON(req.input.Session.AuthorizationDetails))
+			}
 		}
 
 		if req.resp != nil {
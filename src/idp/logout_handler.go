@@ -0,0 +1,204 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// logoutTemplate loads the front-channel logout URIs in hidden iframes and then
+// navigates to the post logout redirect URI, if there is one.
+var logoutTemplate = template.Must(template.New("logout").Parse(`<html>
+<head><title>Logged Out</title></head>
+<body>
+<p>You have been logged out.</p>
+{{range .Frames}}<iframe src='{{.}}' width='0' height='0' hidden></iframe>
+{{end}}{{if .Target}}<script nonce='{{.Nonce}}'>
+window.addEventListener('load', function() { window.location.replace({{.Target}}); });
+</script>
+{{end}}</body>
+</html>`))
+
+// logoutHandler takes action for the RP-Initiated Logout end_session endpoint based on config.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	c := config.GetGlobalConfig()
+	input := getInputData(r)
+	addRequestLogEntry(input, c.LogoutAction.Action)
+
+	switch c.LogoutAction.Action {
+	case "respond":
+		logoutRespond(w, r, input, c)
+	case "error":
+		errorResponse(w, r, &c.LogoutAction.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// logoutRespond checks the id_token_hint and post_logout_redirect_uri, sends the
+// back-channel logout tokens, then renders the front-channel logout iframes and
+// sends the user on to the redirect URI.
+func logoutRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput, c *config.Config) {
+	respond := &c.LogoutAction.Respond
+
+	var claims map[string]any
+	if hint := r.Form.Get("id_token_hint"); hint != "" {
+		if respond.IDTokenHint == "validate" {
+			if err := verifyIDTokenHint(hint); err != nil {
+				logNotice(fmt.Sprintf("invalid id_token_hint: %v", err), r)
+				http.Error(w, "Invalid id_token_hint", http.StatusBadRequest)
+				return
+			}
+		}
+		// An ignored hint may be garbage, in which case there just are no claims.
+		_, claims, _ = keys.DecodeToken(hint)
+	}
+
+	clientID := r.Form.Get("client_id")
+	audience := getAudience(claims["aud"])
+	if clientID == "" && len(audience) != 0 {
+		clientID = audience[0]
+	} else if clientID != "" && len(audience) != 0 && respond.IDTokenHint == "validate" && !audienceMatches(claims["aud"], []string{clientID}) {
+		http.Error(w, "client_id does not match id_token_hint", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI := r.Form.Get("post_logout_redirect_uri")
+	switch respond.PostLogoutRedirect {
+	case "ignore":
+		redirectURI = ""
+	case "validate":
+		client, ok := c.GetClient(clientID)
+		if redirectURI != "" && (!ok || !client.AllowsPostLogoutRedirect(redirectURI)) {
+			logNotice(fmt.Sprintf("unregistered post_logout_redirect_uri %q for client %q", redirectURI, clientID), r)
+			http.Error(w, "Invalid post_logout_redirect_uri", http.StatusBadRequest)
+			return
+		}
+	}
+
+	target := ""
+	if redirectURI != "" {
+		u, err := url.Parse(redirectURI)
+		if err != nil {
+			http.Error(w, "Invalid post_logout_redirect_uri", http.StatusBadRequest)
+			return
+		}
+
+		query := u.Query()
+		for _, p := range respond.Parameters {
+			vals, err := p.Get(input)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(vals) != 0 {
+				query[p.ID] = vals
+			}
+		}
+		u.RawQuery = query.Encode()
+		target = u.String()
+	}
+
+	sid, _ := claims["sid"].(string)
+	if c.BackchannelLogoutConfig.OnEndSession {
+		for _, result := range sendBackchannelLogout(c, input, "", sid) {
+			logNotice(result, r)
+		}
+	}
+
+	var frames []string
+	if respond.FrontchannelLogout {
+		frames = getFrontchannelLogoutURIs(c, "https://"+input.Domain, sid)
+	}
+
+	if len(frames) == 0 && target != "" {
+		http.Redirect(w, r, target, http.StatusFound)
+		return
+	}
+	writeLogoutPage(w, frames, target)
+}
+
+// verifyIDTokenHint checks the id_token_hint was signed by the IdP.
+func verifyIDTokenHint(hint string) error {
+	jwks, err := keys.GetJSONKeySet()
+	if err != nil {
+		return err
+	}
+	return keys.VerifyWithKeySet(hint, jwks)
+}
+
+// getFrontchannelLogoutURIs returns the front-channel logout URI of every
+// configured or registered client, with the iss and sid parameters added.
+func getFrontchannelLogoutURIs(c *config.Config, issuer string, sid string) []string {
+	var uris []string
+	for _, client := range c.AllClients() {
+		if client.FrontchannelLogoutURI == "" {
+			continue
+		}
+
+		u, err := url.Parse(client.FrontchannelLogoutURI)
+		if err != nil {
+			continue
+		}
+
+		query := u.Query()
+		query.Set("iss", issuer)
+		if sid != "" {
+			query.Set("sid", sid)
+		}
+		u.RawQuery = query.Encode()
+		uris = append(uris, u.String())
+	}
+	return uris
+}
+
+// writeLogoutPage renders the logged out page. Only the inline redirect script
+// is allowed to run, and only the front-channel logout origins can be framed.
+func writeLogoutPage(w http.ResponseWriter, frames []string, target string) {
+	nonce, err := getNonce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var origins []string
+	for _, frame := range frames {
+		if origin := getOrigin(frame); origin != "" && !slices.Contains(origins, origin) {
+			origins = append(origins, origin)
+		}
+	}
+
+	csp := fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'", nonce)
+	if len(origins) != 0 {
+		csp += "; frame-src " + strings.Join(origins, " ")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Content-Security-Policy", csp)
+	logoutTemplate.Execute(w, struct {
+		Frames []string
+		Target string
+		Nonce  string
+	}{frames, target, nonce})
+}
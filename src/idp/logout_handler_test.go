@@ -0,0 +1,168 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// signIDTokenHint signs an ID token for the client and session, optionally with a key the IdP doesn't publish.
+func signIDTokenHint(t *testing.T, clientID string, sid string, wrongKey bool) string {
+	t.Helper()
+	token := jwt.New()
+	token.Set("iss", "https://idp.idp")
+	token.Set("aud", clientID)
+	token.Set("sid", sid)
+
+	signed, err := keys.SignToken("RS256", token, wrongKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestLogoutHandler(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	hint := signIDTokenHint(t, "rp", "sid123", false)
+	cases := []struct {
+		title        string
+		respond      config.LogoutRespond
+		frontchannel string
+		params       url.Values
+		wantCode     int
+		wantLocation string
+		wantBody     []string
+	}{
+		{
+			title:        "Registered redirect with state",
+			respond:      config.DefaultConfig.LogoutAction.Respond,
+			params:       url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://rp.example/loggedout"}, "state": {"xyz"}},
+			wantCode:     http.StatusFound,
+			wantLocation: "https://rp.example/loggedout?state=xyz",
+		},
+		{
+			title:        "Registered redirect without state",
+			respond:      config.DefaultConfig.LogoutAction.Respond,
+			params:       url.Values{"client_id": {"rp"}, "post_logout_redirect_uri": {"https://rp.example/loggedout"}},
+			wantCode:     http.StatusFound,
+			wantLocation: "https://rp.example/loggedout",
+		},
+		{
+			title:    "Open redirect rejected",
+			respond:  config.DefaultConfig.LogoutAction.Respond,
+			params:   url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://attacker.example/"}, "state": {"xyz"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			title:    "Redirect without client rejected",
+			respond:  config.DefaultConfig.LogoutAction.Respond,
+			params:   url.Values{"post_logout_redirect_uri": {"https://rp.example/loggedout"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			title:        "Open redirect allowed",
+			respond:      config.LogoutRespond{IDTokenHint: "validate", PostLogoutRedirect: "allow_any", Parameters: config.DefaultConfig.LogoutAction.Respond.Parameters},
+			params:       url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://attacker.example/"}, "state": {"xyz"}},
+			wantCode:     http.StatusFound,
+			wantLocation: "https://attacker.example/?state=xyz",
+		},
+		{
+			title:    "Redirect ignored",
+			respond:  config.LogoutRespond{IDTokenHint: "validate", PostLogoutRedirect: "ignore"},
+			params:   url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://rp.example/loggedout"}},
+			wantCode: http.StatusOK,
+			wantBody: []string{"You have been logged out."},
+		},
+		{
+			title:        "State dropped",
+			respond:      config.LogoutRespond{IDTokenHint: "validate", PostLogoutRedirect: "validate"},
+			params:       url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://rp.example/loggedout"}, "state": {"xyz"}},
+			wantCode:     http.StatusFound,
+			wantLocation: "https://rp.example/loggedout",
+		},
+		{
+			title:    "Wrong key hint rejected",
+			respond:  config.DefaultConfig.LogoutAction.Respond,
+			params:   url.Values{"id_token_hint": {signIDTokenHint(t, "rp", "sid123", true)}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			title:    "Wrong key hint ignored",
+			respond:  config.LogoutRespond{IDTokenHint: "ignore", PostLogoutRedirect: "validate"},
+			params:   url.Values{"id_token_hint": {signIDTokenHint(t, "rp", "sid123", true)}},
+			wantCode: http.StatusOK,
+		},
+		{
+			title:    "Mismatched client_id rejected",
+			respond:  config.DefaultConfig.LogoutAction.Respond,
+			params:   url.Values{"id_token_hint": {hint}, "client_id": {"other"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			title:        "Front-channel logout",
+			respond:      config.DefaultConfig.LogoutAction.Respond,
+			frontchannel: "https://rp.example/frontchannel",
+			params:       url.Values{"id_token_hint": {hint}, "post_logout_redirect_uri": {"https://rp.example/loggedout"}, "state": {"xyz"}},
+			wantCode:     http.StatusOK,
+			wantBody:     []string{"<iframe src='https://rp.example/frontchannel?iss=https%3A%2F%2Fidp.idp&amp;sid=sid123'", "window.location.replace(", "xyz"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.LogoutAction.Respond = tc.respond
+			c.Clients = []config.Client{{
+				ClientID:               "rp",
+				PostLogoutRedirectURIs: []string{"https://rp.example/loggedout"},
+				FrontchannelLogoutURI:  tc.frontchannel,
+			}}
+			config.SetGlobalConfig(&c)
+
+			req, err := http.NewRequest("GET", "https://idp.idp/oauth2/logout?"+tc.params.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(logoutHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("logoutHandler() returned %d rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+
+			if location := rr.Header().Get("Location"); location != tc.wantLocation {
+				t.Errorf("logoutHandler() redirected to %q, expected %q", location, tc.wantLocation)
+			}
+
+			body := rr.Body.String()
+			for _, want := range tc.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("logoutHandler() page is missing %q: %s", want, body)
+				}
+			}
+		})
+	}
+}
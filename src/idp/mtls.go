@@ -0,0 +1,120 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errCertificateBinding is returned when a certificate-bound token is used
+// without its certificate.
+var errCertificateBinding = errors.New("token is bound to another client certificate")
+
+// getClientCertificates returns the certificate chain the client presented
+// over TLS, if any.
+func getClientCertificates(r *http.Request) []*x509.Certificate {
+	if r.TLS == nil {
+		return nil
+	}
+	return r.TLS.PeerCertificates
+}
+
+// certificateThumbprint returns the base64url encoded SHA-256 thumbprint of
+// the first certificate, as used by the x5t#S256 confirmation method.
+func certificateThumbprint(certs []*x509.Certificate) string {
+	if len(certs) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(certs[0].Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// checkTLSClientAuth checks tls_client_auth per RFC 8705 Section 2.1. The
+// certificate must chain to a configured client CA and carry the registered
+// subject DN.
+func checkTLSClientAuth(c *config.MTLSConfig, client *config.Client, input *sessionmgmt.RequestInput) error {
+	if len(input.ClientCertificates) == 0 {
+		return errors.New("no client certificate presented")
+	}
+	if client.SkipCertificateCheck {
+		return nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(c.ClientCAs)) {
+		return errors.New("no trusted client CA certificates configured")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range input.ClientCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	cert := input.ClientCertificates[0]
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   input.Time,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("client certificate is not trusted: %v", err)
+	}
+
+	if subject := cert.Subject.String(); subject != client.TLSClientAuthSubjectDN {
+		return fmt.Errorf("client certificate subject is %q, expected %q", subject, client.TLSClientAuthSubjectDN)
+	}
+	return nil
+}
+
+// checkSelfSignedTLSClientAuth checks self_signed_tls_client_auth per RFC 8705
+// Section 2.2. The certificate public key must be in the client JWKS.
+func checkSelfSignedTLSClientAuth(client *config.Client, input *sessionmgmt.RequestInput) error {
+	if len(input.ClientCertificates) == 0 {
+		return errors.New("no client certificate presented")
+	}
+	if client.SkipCertificateCheck {
+		return nil
+	}
+
+	found, err := keys.KeySetContainsPublicKey(client.JWKS, input.ClientCertificates[0].PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to match client certificate: %v", err)
+	}
+	if !found {
+		return errors.New("client certificate key is not in the client JWKS")
+	}
+	return nil
+}
+
+// checkCertificateBinding checks the use of a certificate-bound token. The
+// request must come with the bound certificate, unless configured to ignore
+// the binding.
+func checkCertificateBinding(c *config.MTLSConfig, input *sessionmgmt.RequestInput) error {
+	if input.Token == nil || input.Token.X5T == "" || c.IgnoreBinding {
+		return nil
+	}
+	if input.Token.X5T != input.CertificateThumbprint {
+		return errCertificateBinding
+	}
+	return nil
+}
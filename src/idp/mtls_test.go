@@ -0,0 +1,256 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"customidp/config"
+	"customidp/keys"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// newCertificate creates a client certificate for the subject, issued by the
+// parent or self-signed if the parent is nil.
+func newCertificate(t *testing.T, subject string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: subject},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// certificateKeySet returns a JSON Key Set holding the certificate public key.
+func certificateKeySet(t *testing.T, cert *x509.Certificate) string {
+	t.Helper()
+	key, err := jwk.New(cert.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := jwk.NewSet()
+	set.Add(key)
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// postMTLSTokenRequest sends a token request over a TLS connection with the
+// client certificate, if any.
+func postMTLSTokenRequest(t *testing.T, form url.Values, cert *x509.Certificate) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest("POST", "https://idp.idp/oauth2/token", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.TLS = &tls.ConnectionState{}
+	if cert != nil {
+		req.TLS.PeerCertificates = []*x509.Certificate{cert}
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(tokenHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMTLSClientAuthentication(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	ca, caKey := newCertificate(t, "Test CA", nil, nil)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	otherCA, otherCAKey := newCertificate(t, "Other CA", nil, nil)
+
+	issued, _ := newCertificate(t, "client.example", ca, caKey)
+	untrusted, _ := newCertificate(t, "client.example", otherCA, otherCAKey)
+	selfSigned, _ := newCertificate(t, "self-signed", nil, nil)
+	otherSelfSigned, _ := newCertificate(t, "self-signed", nil, nil)
+
+	pkiClient := config.Client{ClientID: "pki", TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSubjectDN: "CN=client.example"}
+	selfSignedClient := config.Client{ClientID: "self", TokenEndpointAuthMethod: "self_signed_tls_client_auth", JWKS: certificateKeySet(t, selfSigned)}
+
+	cases := []struct {
+		title    string
+		client   config.Client
+		cert     *x509.Certificate
+		wantCode int
+	}{
+		{title: "PKI certificate", client: pkiClient, cert: issued, wantCode: http.StatusOK},
+		{title: "Missing certificate", client: pkiClient, wantCode: http.StatusUnauthorized},
+		{title: "Untrusted certificate", client: pkiClient, cert: untrusted, wantCode: http.StatusUnauthorized},
+		{
+			title:    "Wrong subject",
+			client:   config.Client{ClientID: "pki", TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSubjectDN: "CN=other.example"},
+			cert:     issued,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			title:    "Untrusted certificate accepted",
+			client:   config.Client{ClientID: "pki", TokenEndpointAuthMethod: "tls_client_auth", TLSClientAuthSubjectDN: "CN=client.example", SkipCertificateCheck: true},
+			cert:     untrusted,
+			wantCode: http.StatusOK,
+		},
+		{title: "Self-signed certificate", client: selfSignedClient, cert: selfSigned, wantCode: http.StatusOK},
+		{title: "Other self-signed certificate", client: selfSignedClient, cert: otherSelfSigned, wantCode: http.StatusUnauthorized},
+		{title: "Self-signed certificate for PKI client", client: pkiClient, cert: selfSigned, wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.Clients = []config.Client{tc.client}
+			c.MTLSConfig.ClientCAs = caPEM
+			config.SetGlobalConfig(&c)
+
+			form := url.Values{"grant_type": {"client_credentials"}, "client_id": {tc.client.ClientID}}
+			rr := postMTLSTokenRequest(t, form, tc.cert)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("tokenHandler() returned %d rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCertificateBoundTokens(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := newCertificate(t, "self-signed", nil, nil)
+	otherCert, _ := newCertificate(t, "self-signed", nil, nil)
+
+	config.SetGlobalConfig(&config.DefaultConfig)
+	rr := postMTLSTokenRequest(t, url.Values{"grant_type": {"client_credentials"}, "client_id": {"client"}}, cert)
+	var content map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &content)
+	accessToken, _ := content["access_token"].(string)
+	if accessToken == "" {
+		t.Fatalf("tokenHandler() did not issue an access token: %v", content)
+	}
+
+	req, err := http.NewRequest("POST", "https://idp.idp/oauth2/introspect", bytes.NewBufferString(url.Values{"token": {accessToken}}.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(introspectHandler).ServeHTTP(rr, req)
+
+	var introspection map[string]any
+	json.Unmarshal(rr.Body.Bytes(), &introspection)
+	cnf, _ := introspection["cnf"].(map[string]any)
+	if want := certificateThumbprint([]*x509.Certificate{cert}); cnf["x5t#S256"] != want {
+		t.Errorf("introspectHandler() returned cnf %v, expected x5t#S256 %s", introspection["cnf"], want)
+	}
+
+	// Signed access tokens carry the binding in their own cnf claim.
+	c := config.DefaultConfig
+	c.TokenAction.Respond.Parameters = []config.Parameter{
+		{ID: "access_token", Action: "custom", CustomKey: "signed_access_token", JSONType: "string"},
+	}
+	config.SetGlobalConfig(&c)
+	rr = postMTLSTokenRequest(t, url.Values{"grant_type": {"client_credentials"}, "client_id": {"client"}}, cert)
+	json.Unmarshal(rr.Body.Bytes(), &content)
+	signed, _ := content["access_token"].(string)
+	_, claims, err := keys.DecodeToken(signed)
+	if err != nil {
+		t.Fatalf("tokenHandler() returned an invalid signed access token: %v", err)
+	}
+	cnf, _ = claims["cnf"].(map[string]any)
+	if want := certificateThumbprint([]*x509.Certificate{cert}); cnf["x5t#S256"] != want {
+		t.Errorf("signed access token has cnf %v, expected x5t#S256 %s", claims["cnf"], want)
+	}
+
+	cases := []struct {
+		title         string
+		cert          *x509.Certificate
+		ignoreBinding bool
+		wantCode      int
+	}{
+		{title: "Bound certificate", cert: cert, wantCode: http.StatusOK},
+		{title: "Missing certificate", wantCode: http.StatusUnauthorized},
+		{title: "Other certificate", cert: otherCert, wantCode: http.StatusUnauthorized},
+		{title: "Other certificate accepted", cert: otherCert, ignoreBinding: true, wantCode: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.MTLSConfig.IgnoreBinding = tc.ignoreBinding
+			config.SetGlobalConfig(&c)
+
+			req, err := http.NewRequest("GET", "https://idp.idp/oauth2/userinfo", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			if tc.cert != nil {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tc.cert}}
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(userInfoHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("userInfoHandler() returned %d rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+
+			if got := rr.Header().Get("WWW-Authenticate"); got != "" && strings.Count(got, `"`) != 4 {
+				t.Errorf("userInfoHandler() returned malformed WWW-Authenticate %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clientAuthParams are pushed request parameters that authenticate the client
+// and must not end up in the authorization redirect.
+var clientAuthParams = []string{"client_secret", "client_assertion", "client_assertion_type"}
+
+// parHandler takes action for the Pushed Authorization Request endpoint based on config.
+func parHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().PARAction
+	input := getInputData(r)
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		parRespond(w, input, &action)
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// parRespond responds with JSON content as configured and stores the pushed
+// parameters under the returned request_uri. Malformed authorization_details
+// are rejected.
+func parRespond(w http.ResponseWriter, input *sessionmgmt.RequestInput, c *config.PARAction) {
+	if _, err := sessionmgmt.ParseAuthorizationDetails(input.FormParams.Get("authorization_details")); err != nil {
+		oauthErrorResponse(w, http.StatusBadRequest, "invalid_authorization_details", err.Error())
+		return
+	}
+
+	content, err := getJSONContent(input, c.Respond.Parameters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	requestURI, _ := content["request_uri"].(string)
+	if requestURI != "" {
+		if c.MalformedRequestURI {
+			requestURI = strings.TrimPrefix(requestURI, config.RequestURIPrefix)
+			content["request_uri"] = requestURI
+		}
+
+		params := url.Values{}
+		for id, vals := range input.FormParams {
+			params[id] = vals
+		}
+		for _, id := range clientAuthParams {
+			params.Del(id)
+		}
+
+		request := sessionmgmt.PushedRequest{
+			RequestURI: requestURI,
+			Params:     params,
+		}
+		if expiresIn, ok := content["expires_in"].(int); ok {
+			request.ExpiresAt = input.Time.Add(time.Duration(expiresIn) * time.Second)
+		}
+		sessionmgmt.AddPushedRequest(request)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, http.StatusCreated, content)
+}
+
+// resolvePushedRequest replaces the input URL parameters with the parameters
+// pushed for the request_uri. A request_uri that was not pushed is left as is
+// unless it has the RFC 9126 prefix.
+func resolvePushedRequest(c *config.PARAction, input *sessionmgmt.RequestInput) error {
+	requestURI := input.URLParams.Get("request_uri")
+	if requestURI == "" {
+		return nil
+	}
+
+	request, err := sessionmgmt.UsePushedRequest(requestURI)
+	if err != nil {
+		if strings.HasPrefix(requestURI, config.RequestURIPrefix) {
+			return err
+		}
+		return nil
+	}
+
+	if request.Uses > 0 && !c.AllowReuse {
+		return errors.New("request_uri was already used")
+	}
+
+	if !request.ExpiresAt.IsZero() && input.Time.After(request.ExpiresAt) {
+		return errors.New("request_uri has expired")
+	}
+
+	params := url.Values{}
+	for id, vals := range request.Params {
+		params[id] = vals
+	}
+
+	// Misbehave by letting query parameters override the pushed ones.
+	if c.IgnorePushedParams {
+		for id, vals := range input.URLParams {
+			params[id] = vals
+		}
+	}
+
+	params.Del("request_uri")
+	input.URLParams = params
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPushedAuthorizationRequest(t *testing.T) {
+	cases := []struct {
+		title string
+		par   func(c *config.PARAction)
+		query url.Values
+		// Number of Authorization endpoint calls with the request_uri.
+		uses      int
+		wantCodes []int
+		wantState string
+	}{
+		{
+			title:     "Pushed parameters",
+			query:     url.Values{"state": {"querystate"}},
+			uses:      2,
+			wantCodes: []int{302, 400},
+			wantState: "pushedstate",
+		},
+		{
+			title:     "Ignore pushed parameters",
+			par:       func(c *config.PARAction) { c.IgnorePushedParams = true },
+			query:     url.Values{"state": {"querystate"}},
+			uses:      1,
+			wantCodes: []int{302},
+			wantState: "querystate",
+		},
+		{
+			title:     "Allow reuse",
+			par:       func(c *config.PARAction) { c.AllowReuse = true },
+			uses:      2,
+			wantCodes: []int{302, 302},
+			wantState: "pushedstate",
+		},
+		{
+			title:     "Malformed request_uri",
+			par:       func(c *config.PARAction) { c.MalformedRequestURI = true },
+			uses:      1,
+			wantCodes: []int{302},
+			wantState: "pushedstate",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			if tc.par != nil {
+				tc.par(&c.PARAction)
+			}
+			config.SetGlobalConfig(&c)
+
+			form := url.Values{
+				"client_id":     {"parclient"},
+				"client_secret": {"secret"},
+				"redirect_uri":  {"https://localhost:8080/callback"},
+				"state":         {"pushedstate"},
+			}
+			req, err := http.NewRequest("POST", "https://idp.idp/oauth2/par", bytes.NewBufferString(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(parHandler).ServeHTTP(rr, req)
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("parHandler() returned %d rather than expected 201", rr.Code)
+			}
+
+			var results map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+				t.Fatalf("Failed to parse json data returned from parHandler() %v", err)
+			}
+
+			requestURI, _ := results["request_uri"].(string)
+			if strings.HasPrefix(requestURI, config.RequestURIPrefix) == c.PARAction.MalformedRequestURI {
+				t.Errorf("parHandler() returned unexpected request_uri %q", requestURI)
+			}
+			if results["expires_in"] != float64(60) {
+				t.Errorf("parHandler() returned expires_in %v, expected 60", results["expires_in"])
+			}
+
+			query := url.Values{"client_id": {"parclient"}, "request_uri": {requestURI}}
+			for id, vals := range tc.query {
+				query[id] = vals
+			}
+
+			for i := 0; i < tc.uses; i++ {
+				req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				rr := httptest.NewRecorder()
+				http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+				if rr.Code != tc.wantCodes[i] {
+					t.Fatalf("authHandler() use %d returned %d rather than expected %d", i, rr.Code, tc.wantCodes[i])
+				}
+				if rr.Code != http.StatusFound {
+					continue
+				}
+
+				location, err := url.Parse(rr.Header().Get("Location"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := location.Scheme + "://" + location.Host + location.Path; got != "https://localhost:8080/callback" {
+					t.Errorf("authHandler() redirected to %q", got)
+				}
+
+				params := location.Query()
+				if params.Get("state") != tc.wantState {
+					t.Errorf("authHandler() returned state %q, expected %q", params.Get("state"), tc.wantState)
+				}
+				if params.Has("request_uri") || params.Has("client_secret") {
+					t.Errorf("authHandler() leaked parameters in the redirect: %v", params)
+				}
+			}
+		})
+	}
+
+	t.Run("Malformed authorization_details", func(t *testing.T) {
+		config.SetGlobalConfig(&config.DefaultConfig)
+		form := url.Values{"client_id": {"parclient"}, "authorization_details": {`{"type":"payment_initiation"}`}}
+		req, err := http.NewRequest("POST", "https://idp.idp/oauth2/par", bytes.NewBufferString(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(parHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "invalid_authorization_details") {
+			t.Errorf("parHandler() returned %d %s rather than expected invalid_authorization_details", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("Unknown request_uri", func(t *testing.T) {
+		config.SetGlobalConfig(&config.DefaultConfig)
+		req, err := http.NewRequest("GET", "/oauth2/auth?request_uri="+url.QueryEscape(config.RequestURIPrefix+"unknown"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("authHandler() returned %d rather than expected 400", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	sessionmgmt "customidp/session"
+	"encoding/base64"
+	"errors"
+)
+
+// checkPKCE validates the code_verifier against the session's code challenge
+// per RFC 7636. The mode allows deliberately lax validation:
+//
+//   - ignore: no validation is done.
+//   - enforce: the verifier must match the challenge using the requested method.
+//   - accept_any_verifier: any verifier is accepted, but one must be present.
+//   - accept_plain_downgrade: the challenge itself is accepted as a verifier for S256.
+//   - accept_missing_verifier: a missing verifier is accepted, a present one is validated.
+func checkPKCE(mode string, input *sessionmgmt.RequestInput) error {
+	if mode == "" || mode == "ignore" {
+		return nil
+	}
+
+	verifier := input.FormParams.Get("code_verifier")
+	challenge := ""
+	method := ""
+	if input.Session != nil {
+		challenge = input.Session.CodeChallenge
+		method = input.Session.CodeChallengeMethod
+	}
+
+	if challenge == "" {
+		// RFC 9700 requires rejecting a verifier when no challenge was sent, this
+		// prevents PKCE downgrade attacks.
+		if verifier != "" && mode == "enforce" {
+			return errors.New("code_verifier sent but no code_challenge was requested")
+		}
+		return nil
+	}
+
+	if verifier == "" {
+		if mode == "accept_missing_verifier" {
+			return nil
+		}
+		return errors.New("missing code_verifier")
+	}
+
+	if mode == "accept_any_verifier" {
+		return nil
+	}
+
+	if mode == "accept_plain_downgrade" && verifierMatches(verifier, challenge, "plain") {
+		return nil
+	}
+
+	if !verifierMatches(verifier, challenge, method) {
+		return errors.New("code_verifier does not match code_challenge")
+	}
+	return nil
+}
+
+// verifierMatches computes the challenge for a verifier and compares it.
+// An empty method defaults to plain per RFC 7636 Section 4.3.
+func verifierMatches(verifier string, challenge string, method string) bool {
+	computed := verifier
+	switch method {
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		computed = base64.RawURLEncoding.EncodeToString(sum[:])
+	case "", "plain":
+	default:
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
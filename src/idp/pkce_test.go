@@ -0,0 +1,110 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	"customidp/session"
+	"net/url"
+	"testing"
+)
+
+func TestCheckPKCE(t *testing.T) {
+	// Example values from RFC 7636 Appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	cases := []struct {
+		title     string
+		mode      string
+		challenge string
+		method    string
+		verifier  string
+		wantErr   bool
+	}{
+		{title: "Ignore wrong verifier", mode: "ignore", challenge: challenge, method: "S256", verifier: "wrong"},
+		{title: "Enforce S256", mode: "enforce", challenge: challenge, method: "S256", verifier: verifier},
+		{title: "Enforce plain", mode: "enforce", challenge: verifier, method: "plain", verifier: verifier},
+		{title: "Enforce default plain", mode: "enforce", challenge: verifier, verifier: verifier},
+		{title: "Enforce wrong verifier", mode: "enforce", challenge: challenge, method: "S256", verifier: "wrong", wantErr: true},
+		{title: "Enforce missing verifier", mode: "enforce", challenge: challenge, method: "S256", wantErr: true},
+		{title: "Enforce unexpected verifier", mode: "enforce", verifier: verifier, wantErr: true},
+		{title: "Enforce plain downgrade", mode: "enforce", challenge: challenge, method: "S256", verifier: challenge, wantErr: true},
+		{title: "Enforce unknown method", mode: "enforce", challenge: verifier, method: "S512", verifier: verifier, wantErr: true},
+		{title: "Any verifier", mode: "accept_any_verifier", challenge: challenge, method: "S256", verifier: "wrong"},
+		{title: "Any verifier missing", mode: "accept_any_verifier", challenge: challenge, method: "S256", wantErr: true},
+		{title: "Plain downgrade", mode: "accept_plain_downgrade", challenge: challenge, method: "S256", verifier: challenge},
+		{title: "Plain downgrade S256", mode: "accept_plain_downgrade", challenge: challenge, method: "S256", verifier: verifier},
+		{title: "Plain downgrade wrong verifier", mode: "accept_plain_downgrade", challenge: challenge, method: "S256", verifier: "wrong", wantErr: true},
+		{title: "Missing verifier", mode: "accept_missing_verifier", challenge: challenge, method: "S256"},
+		{title: "Missing verifier wrong verifier", mode: "accept_missing_verifier", challenge: challenge, method: "S256", verifier: "wrong", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			input := &session.RequestInput{
+				FormParams: url.Values{},
+				Session: &session.Session{
+					CodeChallenge:       tc.challenge,
+					CodeChallengeMethod: tc.method,
+				},
+			}
+			if tc.verifier != "" {
+				input.FormParams.Set("code_verifier", tc.verifier)
+			}
+
+			err := checkPKCE(tc.mode, input)
+			if tc.wantErr && err == nil {
+				t.Errorf("checkPKCE() expected an error but got none")
+			} else if !tc.wantErr && err != nil {
+				t.Errorf("checkPKCE() returned unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestPKCERefreshGrant(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+	c := config.DefaultConfig
+	c.TokenAction.PKCEMode = "enforce"
+	config.SetGlobalConfig(&c)
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	err := session.CreateSession(
+		&session.RequestInput{URLParams: url.Values{
+			"client_id":             {"pkceclient"},
+			"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+			"code_challenge_method": {"S256"},
+		}},
+		url.Values{"code": {"pkcecode"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, results := postTokenRequest(t, url.Values{"grant_type": {"authorization_code"}, "code": {"pkcecode"}, "code_verifier": {verifier}})
+	if code != 200 {
+		t.Fatalf("code exchange returned %d rather than expected 200: %v", code, results)
+	}
+
+	// The refresh grant loads the session with the code_challenge but sends no code_verifier.
+	refreshToken, _ := results["refresh_token"].(string)
+	code, results = postTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+	if code != 200 {
+		t.Errorf("refresh after PKCE code exchange returned %d rather than expected 200: %v", code, results)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"errors"
+)
+
+// checkRefreshToken validates a refresh_token grant against the configured lifecycle
+// and counts the use. Rotate mode retires the token in the same locked update, so
+// concurrent requests can't both use it. It returns the presented token if it is known.
+func checkRefreshToken(c *config.RefreshTokenConfig, input *sessionmgmt.RequestInput) (*sessionmgmt.Token, error) {
+	value := input.FormParams.Get("refresh_token")
+	token, err := sessionmgmt.GetToken(value)
+	if err != nil || token.Type != "refresh_token" {
+		if c.Mode == "" || c.Mode == "accept_any" {
+			return nil, nil
+		}
+		return nil, errors.New("unknown refresh token")
+	}
+
+	replayed := false
+	err = sessionmgmt.UpdateToken(value, func(t *sessionmgmt.Token) error {
+		if t.Revoked {
+			return errors.New("refresh token is revoked")
+		}
+
+		if c.Mode != "" && c.Mode != "accept_any" {
+			if t.Rotated && !(c.Mode == "rotate" && c.AllowReplay) {
+				replayed = true
+				return errors.New("refresh token was already rotated")
+			}
+
+			if c.MaxUses > 0 && t.Uses >= c.MaxUses {
+				return errors.New("refresh token use limit reached")
+			}
+		}
+
+		t.Uses++
+		if c.Mode == "rotate" {
+			t.Rotated = true
+		}
+		token = *t
+		return nil
+	})
+	if err != nil {
+		if replayed && c.RevokeFamilyOnReplay {
+			sessionmgmt.RevokeTokenFamily(token.Family)
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// recordRefreshToken tracks a refresh token issued in the response content.
+// When a refresh token was presented, the new one joins its family and reuse mode
+// returns the presented token instead of a new one.
+func recordRefreshToken(c *config.RefreshTokenConfig, input *sessionmgmt.RequestInput, presented *sessionmgmt.Token, content map[string]any) {
+	issued, ok := content["refresh_token"].(string)
+	if !ok || issued == "" {
+		return
+	}
+
+	if presented != nil && c.Mode == "reuse" {
+		content["refresh_token"] = presented.Value
+		return
+	}
+
+	token := newIssuedToken(input, issued, "refresh_token", content)
+	if presented != nil {
+		token.Family = presented.Family
+	}
+	sessionmgmt.AddToken(token)
+}
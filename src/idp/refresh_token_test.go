@@ -0,0 +1,192 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/session"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// postTokenRequest calls the tokenHandler with form parameters and returns the
+// status code and parsed JSON response.
+func postTokenRequest(t *testing.T, form url.Values) (int, map[string]any) {
+	t.Helper()
+	req, err := http.NewRequest("POST", "https://idp.idp/oauth2/token", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(tokenHandler).ServeHTTP(rr, req)
+
+	var results map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to parse json data returned from tokenHandler() %v", err)
+	}
+	return rr.Code, results
+}
+
+func TestRefreshTokenLifecycle(t *testing.T) {
+	cases := []struct {
+		title        string
+		refreshToken config.RefreshTokenConfig
+		// Each step refreshes with the token returned by the step at the index,
+		// -1 is the token from the code exchange.
+		steps     []int
+		wantCodes []int
+		// Whether each step returned the same refresh token as it was given.
+		wantSame []bool
+	}{
+		{
+			title:        "Accept any",
+			refreshToken: config.RefreshTokenConfig{Mode: "accept_any"},
+			steps:        []int{-1, -1, 0},
+			wantCodes:    []int{200, 200, 200},
+			wantSame:     []bool{false, false, false},
+		},
+		{
+			title:        "Rotate",
+			refreshToken: config.RefreshTokenConfig{Mode: "rotate"},
+			steps:        []int{-1, 0, 1},
+			wantCodes:    []int{200, 200, 200},
+			wantSame:     []bool{false, false, false},
+		},
+		{
+			title:        "Rotate rejects replay",
+			refreshToken: config.RefreshTokenConfig{Mode: "rotate"},
+			steps:        []int{-1, -1, 0},
+			wantCodes:    []int{200, 400, 200},
+			wantSame:     []bool{false, false, false},
+		},
+		{
+			title:        "Rotate revokes family on replay",
+			refreshToken: config.RefreshTokenConfig{Mode: "rotate", RevokeFamilyOnReplay: true},
+			steps:        []int{-1, -1, 0},
+			wantCodes:    []int{200, 400, 400},
+			wantSame:     []bool{false},
+		},
+		{
+			title:        "Rotate allows replay",
+			refreshToken: config.RefreshTokenConfig{Mode: "rotate", AllowReplay: true},
+			steps:        []int{-1, -1},
+			wantCodes:    []int{200, 200},
+			wantSame:     []bool{false, false},
+		},
+		{
+			title:        "Reuse",
+			refreshToken: config.RefreshTokenConfig{Mode: "reuse"},
+			steps:        []int{-1, 0, 1},
+			wantCodes:    []int{200, 200, 200},
+			wantSame:     []bool{true, true, true},
+		},
+		{
+			title:        "Reuse limited",
+			refreshToken: config.RefreshTokenConfig{Mode: "reuse", MaxUses: 2},
+			steps:        []int{-1, 0, 1},
+			wantCodes:    []int{200, 200, 400},
+			wantSame:     []bool{true, true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			config.SetGlobalConfig(&config.Config{
+				TokenAction: config.TokenAction{
+					Action: "respond",
+					Respond: config.TokenRespond{
+						Parameters: []config.Parameter{
+							{ID: "refresh_token", Action: "random", JSONType: "string"},
+							{ID: "client_id", Action: "set", Values: []string{"{{.Session.ClientID}}"}, JSONType: "string"},
+						},
+					},
+					RefreshToken: tc.refreshToken,
+				},
+			})
+
+			code := tc.title + "code"
+			err := session.CreateSession(
+				&session.RequestInput{URLParams: url.Values{"client_id": {"refreshclient"}}},
+				url.Values{"code": {code}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gotCode, results := postTokenRequest(t, url.Values{"grant_type": {"authorization_code"}, "code": {code}})
+			if gotCode != 200 {
+				t.Fatalf("code exchange returned %d rather than expected 200", gotCode)
+			}
+			initial, _ := results["refresh_token"].(string)
+
+			issued := []string{}
+			for i, step := range tc.steps {
+				presented := initial
+				if step >= 0 {
+					presented = issued[step]
+				}
+
+				gotCode, results := postTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {presented}})
+				if gotCode != tc.wantCodes[i] {
+					t.Fatalf("step %d returned %d rather than expected %d", i, gotCode, tc.wantCodes[i])
+				}
+
+				got, _ := results["refresh_token"].(string)
+				issued = append(issued, got)
+				if gotCode != 200 {
+					continue
+				}
+
+				if results["client_id"] != "refreshclient" {
+					t.Errorf("step %d did not resolve the session, got client_id %v", i, results["client_id"])
+				}
+
+				if (got == presented) != tc.wantSame[i] {
+					t.Errorf("step %d returned refresh token %q for %q, expected same %v", i, got, presented, tc.wantSame[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentRefreshTokenRotation(t *testing.T) {
+	session.AddToken(session.Token{Value: "concurrent", Type: "refresh_token"})
+	c := config.RefreshTokenConfig{Mode: "rotate"}
+	input := &session.RequestInput{FormParams: url.Values{"refresh_token": {"concurrent"}}}
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := checkRefreshToken(&c, input); err == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := accepted.Load(); got != 1 {
+		t.Errorf("checkRefreshToken() accepted a rotated refresh token %d times, expected once", got)
+	}
+}
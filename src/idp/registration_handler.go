@@ -0,0 +1,200 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"crypto/subtle"
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// registrationHandler takes action for the Dynamic Client Registration endpoint
+// (RFC 7591) and its client configuration endpoint (RFC 7592) based on config.
+func registrationHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().RegistrationAction
+	input := getInputData(r)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		input.Registration = readRegistration(r)
+	}
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		switch r.Method {
+		case http.MethodPost:
+			registerRespond(w, r, input, &action.Respond)
+		case http.MethodGet, http.MethodDelete:
+			clientConfigurationRespond(w, r, input)
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// readRegistration decodes the JSON client metadata of a registration request,
+// returning nil if it isn't a JSON object. The software statement is decoded
+// without verification.
+func readRegistration(r *http.Request) *sessionmgmt.Registration {
+	metadata := map[string]any{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&metadata); err != nil {
+		logError(fmt.Sprintf("failed to decode client metadata: %v", err), r)
+		return nil
+	}
+
+	registration := &sessionmgmt.Registration{Metadata: metadata}
+	if statement, ok := metadata["software_statement"].(string); ok {
+		registration.SoftwareStatement = decodeJWT(statement)
+	}
+	return registration
+}
+
+// registerRespond registers a client and returns the client information response.
+func registerRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput, c *config.RegistrationRespond) {
+	if input.Registration == nil {
+		oauthErrorResponse(w, http.StatusBadRequest, "invalid_client_metadata", "client metadata must be a JSON object")
+		return
+	}
+
+	if err := checkSoftwareStatement(&c.SoftwareStatement, input.Registration); err != nil {
+		logNotice(fmt.Sprintf("software statement rejected: %v", err), r)
+		oauthErrorResponse(w, http.StatusBadRequest, "invalid_software_statement", err.Error())
+		return
+	}
+
+	content, err := getRegistrationContent(input, c.Parameters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	clientID, _ := content["client_id"].(string)
+	accessToken, _ := content["registration_access_token"].(string)
+	if _, ok := content["registration_client_uri"]; !ok && clientID != "" && accessToken != "" {
+		content["registration_client_uri"] = "https://" + input.Domain + "/oauth2/register?client_id=" + url.QueryEscape(clientID)
+	}
+
+	if clientID != "" {
+		secret, _ := content["client_secret"].(string)
+		sessionmgmt.AddRegisteredClient(sessionmgmt.RegisteredClient{
+			ClientID:                clientID,
+			ClientSecret:            secret,
+			RegistrationAccessToken: accessToken,
+			Metadata:                content,
+			RegisteredAt:            input.Time,
+		})
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, http.StatusCreated, content)
+}
+
+// checkSoftwareStatement checks the software statement as configured. When the
+// statement is used its claims replace the matching client metadata.
+func checkSoftwareStatement(c *config.SoftwareStatementConfig, registration *sessionmgmt.Registration) error {
+	if c.Validation == "ignore" {
+		return nil
+	}
+
+	if _, ok := registration.Metadata["software_statement"]; !ok {
+		if c.Validation == "require" {
+			return errors.New("missing software_statement")
+		}
+		return nil
+	}
+
+	statement := registration.SoftwareStatement
+	if statement == nil {
+		return errors.New("malformed software_statement")
+	}
+
+	if c.Validation == "verify" || c.Validation == "require" {
+		if err := keys.VerifyWithKeySet(statement.Raw, c.JWKS); err != nil {
+			return fmt.Errorf("software_statement signature is invalid: %v", err)
+		}
+	}
+
+	for id, claim := range statement.Claims {
+		if !jwtClaims[id] {
+			registration.Metadata[id] = claim
+		}
+	}
+	return nil
+}
+
+// getRegistrationContent returns the requested client metadata with the
+// parameters applied. Parameters replace the requested values, omit removes
+// them, and passthrough keeps them as requested.
+func getRegistrationContent(input *sessionmgmt.RequestInput, parameters []config.Parameter) (map[string]any, error) {
+	content := make(map[string]any)
+	for id, val := range input.Registration.Metadata {
+		content[id] = val
+	}
+
+	for _, p := range parameters {
+		switch p.Action {
+		case "passthrough":
+			continue
+		case "omit":
+			delete(content, p.ID)
+			continue
+		}
+
+		val, err := p.GetJSON(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if val != nil {
+			content[p.ID] = val
+		}
+	}
+	return content, nil
+}
+
+// clientConfigurationRespond reads or deletes a registered client. The
+// registration_access_token must be presented as a Bearer token.
+func clientConfigurationRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput) {
+	client, err := sessionmgmt.GetRegisteredClient(input.URLParams.Get("client_id"))
+	accessToken, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if err != nil || client.RegistrationAccessToken == "" ||
+		subtle.ConstantTimeCompare([]byte(accessToken), []byte(client.RegistrationAccessToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		oauthErrorResponse(w, http.StatusUnauthorized, "invalid_token", "unknown client or invalid registration access token")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		sessionmgmt.DeleteRegisteredClient(client.ClientID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, http.StatusOK, client.Metadata)
+}
@@ -0,0 +1,238 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/keys"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// callRegistration calls the registration endpoint and decodes any JSON response.
+func callRegistration(t *testing.T, method string, target string, body string, accessToken string) (int, map[string]any) {
+	t.Helper()
+	req, err := http.NewRequest(method, target, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(registrationHandler).ServeHTTP(rr, req)
+
+	content := map[string]any{}
+	if rr.Body.Len() != 0 {
+		json.Unmarshal(rr.Body.Bytes(), &content)
+	}
+	return rr.Code, content
+}
+
+// signSoftwareStatement signs a software statement with the IdP keys.
+func signSoftwareStatement(t *testing.T, wrongKey bool) string {
+	t.Helper()
+	token := jwt.New()
+	token.Set("iss", "https://directory.example")
+	token.Set("software_id", "trusted-software")
+	token.Set("client_name", "Trusted Client")
+
+	signed, err := keys.SignToken("RS256", token, wrongKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestRegistrationHandler(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := keys.GetJSONKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := func(extra string) string {
+		return `{"redirect_uris":["https://client.example/cb"],"client_name":"Requested Name"` + extra + `}`
+	}
+	statement := `,"software_statement":"` + signSoftwareStatement(t, false) + `"`
+	wrongKeyStatement := `,"software_statement":"` + signSoftwareStatement(t, true) + `"`
+
+	cases := []struct {
+		title          string
+		parameters     []config.Parameter
+		validation     string
+		body           string
+		wantCode       int
+		wantError      string
+		wantName       string
+		wantRedirects  []any
+		wantSoftwareID any
+	}{
+		{
+			title:         "Register client",
+			validation:    "accept",
+			body:          metadata(""),
+			wantCode:      http.StatusCreated,
+			wantName:      "Requested Name",
+			wantRedirects: []any{"https://client.example/cb"},
+		},
+		{
+			title:         "Inconsistent redirect URIs",
+			parameters:    []config.Parameter{{ID: "redirect_uris", Action: "set", Values: []string{"https://attacker.example/cb"}, JSONType: "array"}},
+			validation:    "accept",
+			body:          metadata(""),
+			wantCode:      http.StatusCreated,
+			wantName:      "Requested Name",
+			wantRedirects: []any{"https://attacker.example/cb"},
+		},
+		{
+			title:      "Omitted metadata",
+			parameters: []config.Parameter{{ID: "redirect_uris", Action: "omit"}},
+			validation: "accept",
+			body:       metadata(""),
+			wantCode:   http.StatusCreated,
+			wantName:   "Requested Name",
+		},
+		{
+			title:          "Software statement claims used",
+			validation:     "verify",
+			body:           metadata(statement),
+			wantCode:       http.StatusCreated,
+			wantName:       "Trusted Client",
+			wantRedirects:  []any{"https://client.example/cb"},
+			wantSoftwareID: "trusted-software",
+		},
+		{
+			title:          "Forged software statement accepted",
+			validation:     "accept",
+			body:           metadata(wrongKeyStatement),
+			wantCode:       http.StatusCreated,
+			wantName:       "Trusted Client",
+			wantRedirects:  []any{"https://client.example/cb"},
+			wantSoftwareID: "trusted-software",
+		},
+		{
+			title:         "Software statement ignored",
+			validation:    "ignore",
+			body:          metadata(statement),
+			wantCode:      http.StatusCreated,
+			wantName:      "Requested Name",
+			wantRedirects: []any{"https://client.example/cb"},
+		},
+		{
+			title:      "Forged software statement rejected",
+			validation: "verify",
+			body:       metadata(wrongKeyStatement),
+			wantCode:   http.StatusBadRequest,
+			wantError:  "invalid_software_statement",
+		},
+		{
+			title:      "Missing software statement",
+			validation: "require",
+			body:       metadata(""),
+			wantCode:   http.StatusBadRequest,
+			wantError:  "invalid_software_statement",
+		},
+		{
+			title:      "Malformed metadata",
+			validation: "accept",
+			body:       `["not", "an", "object"]`,
+			wantCode:   http.StatusBadRequest,
+			wantError:  "invalid_client_metadata",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.RegistrationAction.Respond.Parameters = append(tc.parameters, config.DefaultConfig.RegistrationAction.Respond.Parameters...)
+			c.RegistrationAction.Respond.SoftwareStatement = config.SoftwareStatementConfig{Validation: tc.validation, JWKS: jwks}
+			config.SetGlobalConfig(&c)
+
+			code, content := callRegistration(t, "POST", "https://idp.idp/oauth2/register", tc.body, "")
+			if code != tc.wantCode {
+				t.Fatalf("registrationHandler() returned %d rather than expected %d: %v", code, tc.wantCode, content)
+			}
+			if tc.wantError != "" {
+				if content["error"] != tc.wantError {
+					t.Errorf("registrationHandler() returned error %v, expected %s", content["error"], tc.wantError)
+				}
+				return
+			}
+
+			for _, id := range []string{"client_id", "client_secret", "registration_access_token"} {
+				if val, _ := content[id].(string); val == "" {
+					t.Errorf("registrationHandler() returned no %s: %v", id, content)
+				}
+			}
+			if content["client_name"] != tc.wantName {
+				t.Errorf("registrationHandler() returned client_name %v, expected %s", content["client_name"], tc.wantName)
+			}
+			if redirects, _ := content["redirect_uris"].([]any); !reflect.DeepEqual(redirects, tc.wantRedirects) {
+				t.Errorf("registrationHandler() returned redirect_uris %v, expected %v", content["redirect_uris"], tc.wantRedirects)
+			}
+			if content["software_id"] != tc.wantSoftwareID {
+				t.Errorf("registrationHandler() returned software_id %v, expected %v", content["software_id"], tc.wantSoftwareID)
+			}
+
+			wantURI := "https://idp.idp/oauth2/register?client_id=" + url.QueryEscape(content["client_id"].(string))
+			if content["registration_client_uri"] != wantURI {
+				t.Errorf("registrationHandler() returned registration_client_uri %v, expected %s", content["registration_client_uri"], wantURI)
+			}
+		})
+	}
+}
+
+func TestClientConfigurationEndpoint(t *testing.T) {
+	config.SetGlobalConfig(&config.DefaultConfig)
+	code, registered := callRegistration(t, "POST", "https://idp.idp/oauth2/register", `{"client_name":"Managed Client"}`, "")
+	if code != http.StatusCreated {
+		t.Fatalf("registrationHandler() returned %d: %v", code, registered)
+	}
+	target := registered["registration_client_uri"].(string)
+	accessToken := registered["registration_access_token"].(string)
+
+	if code, _ := callRegistration(t, "GET", target, "", "wrongtoken"); code != http.StatusUnauthorized {
+		t.Errorf("reading with a wrong token returned %d rather than expected 401", code)
+	}
+
+	code, read := callRegistration(t, "GET", target, "", accessToken)
+	if code != http.StatusOK {
+		t.Fatalf("reading the client returned %d rather than expected 200", code)
+	}
+	if !reflect.DeepEqual(read, registered) {
+		t.Errorf("reading the client returned %v, expected %v", read, registered)
+	}
+
+	if code, _ := callRegistration(t, "DELETE", target, "", accessToken); code != http.StatusNoContent {
+		t.Errorf("deleting the client returned %d rather than expected 204", code)
+	}
+	if code, _ := callRegistration(t, "GET", target, "", accessToken); code != http.StatusUnauthorized {
+		t.Errorf("reading a deleted client returned %d rather than expected 401", code)
+	}
+}
@@ -20,16 +20,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"google.golang.org/appengine/v2"
 )
 
+// outboundClient is the HTTP client used for calls to upstream IdPs and clients.
+var outboundClient = &http.Client{Timeout: 30 * time.Second}
+
 // errorResponse returns an error based on configuration.
 func errorResponse(w http.ResponseWriter, r *http.Request, e *config.Error) {
 	http.Error(w, e.ErrorContent, e.ErrorCode)
 }
 
+// oauthErrorResponse returns an RFC 6749 Section 5.2 style JSON error.
+func oauthErrorResponse(w http.ResponseWriter, code int, errCode string, description string) {
+	content := map[string]string{"error": errCode}
+	if description != "" {
+		content["error_description"] = description
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	writeJSON(w, code, content)
+}
+
 // blockResponse blocks the return of a handler for a long time.
 func blockResponse(w http.ResponseWriter) {
 	time.Sleep(10 * time.Minute)
@@ -38,25 +54,41 @@ func blockResponse(w http.ResponseWriter) {
 
 // jsonResponse builds a JSON formated response from configured Parameter values.
 func jsonResponse(w http.ResponseWriter, input *sessionmgmt.RequestInput, parameters []config.Parameter) {
+	content, err := getJSONContent(input, parameters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, content)
+}
+
+// getJSONContent evaluates configured Parameter values into JSON content.
+func getJSONContent(input *sessionmgmt.RequestInput, parameters []config.Parameter) (map[string]any, error) {
 	content := map[string]any{}
 	for _, configParam := range parameters {
 		jsonVal, err := configParam.GetJSON(input)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 
 		if jsonVal != nil {
 			content[configParam.ID] = jsonVal
 		}
 	}
+	return content, nil
+}
 
+// writeJSON marshals content and writes it as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, content any) {
 	resp, err := json.Marshal(content)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to marshal content %v", err), http.StatusBadRequest)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
 	fmt.Fprint(w, string(resp))
 }
 
@@ -90,14 +122,80 @@ func getInputData(r *http.Request) *sessionmgmt.RequestInput {
 		}
 	}
 
+	// For device code grants, load the session of the device authorization.
+	if r.Form.Get("device_code") != "" {
+		device, err := sessionmgmt.GetDeviceAuthorization(r.Form.Get("device_code"))
+		if err != nil {
+			logError(fmt.Sprintf("unexpected device code: %v", err), r)
+		} else {
+			session = device.Session
+		}
+	}
+
+	// For CIBA grants, load the session of the backchannel authorization.
+	if r.Form.Get("auth_req_id") != "" {
+		auth, err := sessionmgmt.GetBackchannelAuthorization(r.Form.Get("auth_req_id"))
+		if err != nil {
+			logError(fmt.Sprintf("unexpected auth_req_id: %v", err), r)
+		} else {
+			session = auth.Session
+		}
+	}
+
+	// For refresh token grants, load the session the refresh token was issued for.
+	if r.Form.Get("grant_type") == "refresh_token" && r.Form.Get("refresh_token") != "" {
+		token, err := sessionmgmt.GetToken(r.Form.Get("refresh_token"))
+		if err != nil {
+			logError(fmt.Sprintf("unexpected refresh token: %v", err), r)
+		} else {
+			session = token.Session
+		}
+	}
+
+	// For introspection, revocation and userinfo, load the presented token and its session.
+	// Tokens are presented as a parameter, or as a Bearer or DPoP token.
+	var presentedToken *sessionmgmt.Token
+	tokenValue := r.Form.Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && tokenValue == "" {
+		tokenValue = bearer
+	} else if dpop, ok := strings.CutPrefix(r.Header.Get("Authorization"), "DPoP "); ok && tokenValue == "" {
+		tokenValue = dpop
+	}
+	if tokenValue != "" {
+		token, err := sessionmgmt.GetToken(tokenValue)
+		if err == nil {
+			presentedToken = &token
+			session = token.Session
+		}
+	}
+
+	var tokenExchange *sessionmgmt.TokenExchange
+	if r.Form.Get("grant_type") == tokenExchangeGrantType {
+		tokenExchange = getTokenExchange(r)
+	}
+
+	var assertion *sessionmgmt.JWT
+	if r.Form.Get("grant_type") == jwtBearerGrantType {
+		assertion = decodeJWT(r.Form.Get("assertion"))
+	}
+
 	return &sessionmgmt.RequestInput{
-		HTTPMethod: r.Method,
-		Path:       r.URL.Path,
-		Proto:      r.Header.Get("X-Forwarded-Proto"),
-		Headers:    r.Header,
-		URLParams:  r.URL.Query(),
-		FormParams: r.PostForm,
-		Domain:     getDomain(r),
-		Session:    &session,
-		Time:       time.Now()}
+		HTTPMethod:    r.Method,
+		Path:          r.URL.Path,
+		Proto:         r.Header.Get("X-Forwarded-Proto"),
+		Headers:       r.Header,
+		URLParams:     r.URL.Query(),
+		FormParams:    r.PostForm,
+		Domain:        getDomain(r),
+		Session:       &session,
+		Time:          time.Now(),
+		TokenExchange: tokenExchange,
+		Assertion:     assertion,
+		Token:         presentedToken,
+		ClientID:      getRequestClientID(r),
+		DPoP:          decodeDPoPProof(r.Header.Get("DPoP")),
+
+		ClientCertificates:    getClientCertificates(r),
+		CertificateThumbprint: certificateThumbprint(getClientCertificates(r)),
+	}
 }
@@ -0,0 +1,195 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// jwtClaims are registered JWT claims of a request object that are not
+// authorization request parameters.
+var jwtClaims = map[string]bool{"iss": true, "aud": true, "exp": true, "iat": true, "nbf": true, "jti": true}
+
+// resolveRequestObject merges the claims of a request parameter or request_uri
+// request object into the input URL parameters. Request object claims take
+// precedence over query parameters unless configured otherwise.
+func resolveRequestObject(c *config.RequestObjectConfig, input *sessionmgmt.RequestInput) error {
+	raw := input.URLParams.Get("request")
+	if raw == "" {
+		requestURI := input.URLParams.Get("request_uri")
+		if requestURI == "" || !c.FetchRequestURI {
+			return nil
+		}
+
+		var err error
+		raw, err = fetchRequestObject(c, input.Domain, requestURI)
+		if err != nil {
+			return err
+		}
+	}
+
+	obj := decodeJWT(raw)
+	if obj == nil {
+		return errors.New("request object is not a JWT")
+	}
+	input.RequestObject = obj
+
+	alg, _ := obj.Header["alg"].(string)
+	if alg == "" || alg == "none" || strings.HasSuffix(obj.Raw, ".") {
+		if !c.AcceptUnsigned {
+			return errors.New("request object is not signed")
+		}
+	} else if c.VerifySignature {
+		clientID := input.URLParams.Get("client_id")
+		if id, ok := obj.Claims["client_id"].(string); ok {
+			clientID = id
+		}
+		jwks := ""
+		for _, key := range c.ClientKeys {
+			if key.ClientID == clientID {
+				jwks = key.JWKS
+			}
+		}
+		if jwks == "" {
+			return fmt.Errorf("no key configured for client %q", clientID)
+		}
+		if err := keys.VerifyWithKeySet(obj.Raw, jwks); err != nil {
+			return fmt.Errorf("request object signature is invalid: %v", err)
+		}
+	}
+
+	claims, err := requestObjectParams(obj.Claims)
+	if err != nil {
+		return err
+	}
+
+	if c.RequireConsistent {
+		for _, id := range []string{"client_id", "response_type"} {
+			if claims.Has(id) && input.URLParams.Has(id) && claims.Get(id) != input.URLParams.Get(id) {
+				return fmt.Errorf("%s does not match the request object", id)
+			}
+		}
+	}
+
+	params := url.Values{}
+	first, second := input.URLParams, claims
+	if c.Precedence == "query" {
+		first, second = claims, input.URLParams
+	}
+	for id, vals := range first {
+		params[id] = vals
+	}
+	for id, vals := range second {
+		params[id] = vals
+	}
+
+	params.Del("request")
+	params.Del("request_uri")
+	input.URLParams = params
+	return nil
+}
+
+// fetchRequestObject gets the request object a request_uri URL points to.
+// Only allowed hosts are fetched, also when redirected.
+func fetchRequestObject(c *config.RequestObjectConfig, domain string, requestURI string) (string, error) {
+	u, err := url.Parse(requestURI)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", errors.New("request_uri is not a http or https URL")
+	}
+	if !requestURIAllowed(c, domain, u) {
+		return "", fmt.Errorf("request_uri host %q is not allowed", u.Hostname())
+	}
+
+	client := &http.Client{
+		Timeout: outboundClient.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("request_uri redirected too many times")
+			}
+			if !requestURIAllowed(c, domain, req.URL) {
+				return fmt.Errorf("request_uri redirected to host %q that is not allowed", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request_uri returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// requestURIAllowed reports whether a request_uri URL can be fetched. Only the
+// IdP's own host, loopback addresses and the allowed hosts are fetched, so the
+// IdP can't be made to request arbitrary addresses.
+func requestURIAllowed(c *config.RequestObjectConfig, domain string, u *url.URL) bool {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" || strings.EqualFold(host, (&url.URL{Host: domain}).Hostname()) || slices.Contains(c.RequestURIHosts, host) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requestObjectParams converts request object claims to authorization request
+// parameters. Non-string claims such as claims or authorization_details are
+// JSON encoded.
+func requestObjectParams(claims map[string]any) (url.Values, error) {
+	params := url.Values{}
+	for id, claim := range claims {
+		if jwtClaims[id] {
+			continue
+		}
+
+		switch v := claim.(type) {
+		case string:
+			params.Set(id, v)
+		case float64, bool:
+			params.Set(id, fmt.Sprint(v))
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			params.Set(id, string(b))
+		}
+	}
+	return params, nil
+}
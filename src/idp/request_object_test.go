@@ -0,0 +1,193 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+func TestRequestObject(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := keys.GetJSONKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makeRequestObject := func(alg string, wrongKey bool) string {
+		token := jwt.New()
+		token.Set("iss", "jarclient")
+		token.Set("aud", "https://idp.idp")
+		token.Set("client_id", "jarclient")
+		token.Set("response_type", "code")
+		token.Set("redirect_uri", "https://localhost:8080/callback")
+		token.Set("state", "objectstate")
+		token.Set("max_age", 300)
+		token.Set("claims", map[string]any{"id_token": map[string]any{"acr": nil}})
+		signed, err := keys.SignToken(alg, token, wrongKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	signed := makeRequestObject("RS256", false)
+	wrongKey := makeRequestObject("RS256", true)
+	unsigned := makeRequestObject("none", false)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, signed)
+	}))
+	defer server.Close()
+
+	verify := config.RequestObjectConfig{
+		VerifySignature: true,
+		ClientKeys:      []config.ClientKey{{ClientID: "jarclient", JWKS: jwks}},
+	}
+
+	cases := []struct {
+		title     string
+		config    config.RequestObjectConfig
+		query     url.Values
+		wantCode  int
+		wantState string
+	}{
+		{
+			title:     "Request object overrides query",
+			config:    config.RequestObjectConfig{AcceptUnsigned: true},
+			query:     url.Values{"request": {unsigned}},
+			wantCode:  302,
+			wantState: "objectstate",
+		},
+		{
+			title:     "Query precedence",
+			config:    config.RequestObjectConfig{AcceptUnsigned: true, Precedence: "query"},
+			query:     url.Values{"request": {unsigned}},
+			wantCode:  302,
+			wantState: "querystate",
+		},
+		{
+			title:    "Unsigned rejected",
+			config:   verify,
+			query:    url.Values{"request": {unsigned}},
+			wantCode: 400,
+		},
+		{
+			title:     "Verified signature",
+			config:    verify,
+			query:     url.Values{"request": {signed}},
+			wantCode:  302,
+			wantState: "objectstate",
+		},
+		{
+			title:    "Wrong key",
+			config:   verify,
+			query:    url.Values{"request": {wrongKey}},
+			wantCode: 400,
+		},
+		{
+			title:    "No client key",
+			config:   config.RequestObjectConfig{VerifySignature: true},
+			query:    url.Values{"request": {signed}},
+			wantCode: 400,
+		},
+		{
+			title:     "Fetched request_uri",
+			config:    config.RequestObjectConfig{FetchRequestURI: true},
+			query:     url.Values{"request_uri": {server.URL}},
+			wantCode:  302,
+			wantState: "objectstate",
+		},
+		{
+			title:    "Disallowed request_uri host",
+			config:   config.RequestObjectConfig{FetchRequestURI: true},
+			query:    url.Values{"request_uri": {"http://169.254.169.254/latest/meta-data"}},
+			wantCode: 400,
+		},
+		{
+			title:     "Allowed request_uri host",
+			config:    config.RequestObjectConfig{FetchRequestURI: true, RequestURIHosts: []string{"127.0.0.1"}},
+			query:     url.Values{"request_uri": {server.URL}},
+			wantCode:  302,
+			wantState: "objectstate",
+		},
+		{
+			title:    "Mismatched client_id",
+			config:   config.RequestObjectConfig{RequireConsistent: true},
+			query:    url.Values{"request": {signed}, "client_id": {"otherclient"}},
+			wantCode: 400,
+		},
+		{
+			title:    "Not a JWT",
+			query:    url.Values{"request": {"notajwt"}},
+			wantCode: 400,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.AuthAction.RequestObject = tc.config
+			config.SetGlobalConfig(&c)
+
+			query := url.Values{"client_id": {"jarclient"}, "response_type": {"code"}, "state": {"querystate"}}
+			for id, vals := range tc.query {
+				query[id] = vals
+			}
+
+			req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("authHandler() returned %d rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+			if rr.Code != http.StatusFound {
+				return
+			}
+
+			location, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := location.Query()
+			if params.Get("state") != tc.wantState {
+				t.Errorf("authHandler() returned state %q, expected %q", params.Get("state"), tc.wantState)
+			}
+			if params.Get("max_age") != "300" {
+				t.Errorf("authHandler() returned max_age %q, expected 300", params.Get("max_age"))
+			}
+			if params.Get("claims") != `{"id_token":{"acr":null}}` {
+				t.Errorf("authHandler() returned claims %q", params.Get("claims"))
+			}
+			if params.Has("request") || params.Has("request_uri") || params.Has("iss") {
+				t.Errorf("authHandler() returned request object parameters: %v", params)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// checkResources checks that resource indicators are absolute URIs without a
+// fragment, per RFC 8707 Section 2.
+func checkResources(resources []string) error {
+	for _, resource := range resources {
+		u, err := url.Parse(resource)
+		if err != nil || !u.IsAbs() {
+			return fmt.Errorf("resource %q is not an absolute URI", resource)
+		}
+		if u.Fragment != "" || u.RawFragment != "" {
+			return fmt.Errorf("resource %q has a fragment", resource)
+		}
+	}
+	return nil
+}
+
+// grantResources returns the resources granted as the token audience. The
+// requested resources can narrow the authorized ones, the resources of the
+// authorization request, but not add others. Without requested resources all
+// authorized ones are granted. Misbehaving modes ignore the resources, grant
+// another resource, or grant all resources at once.
+func grantResources(c *config.ResourceConfig, authorized []string, requested []string) ([]string, error) {
+	switch c.Grant {
+	case "ignore":
+		return nil, nil
+	case "other":
+		if c.OtherResource == "" {
+			return nil, nil
+		}
+		return []string{c.OtherResource}, nil
+	}
+
+	if err := checkResources(requested); err != nil {
+		return nil, err
+	}
+
+	if c.Grant == "all" {
+		granted := slices.Clone(authorized)
+		for _, resource := range requested {
+			if !slices.Contains(granted, resource) {
+				granted = append(granted, resource)
+			}
+		}
+		return granted, nil
+	}
+
+	if len(requested) == 0 {
+		return authorized, nil
+	}
+	if len(authorized) > 0 {
+		for _, resource := range requested {
+			if !slices.Contains(authorized, resource) {
+				return nil, fmt.Errorf("resource %q was not authorized", resource)
+			}
+		}
+	}
+	return requested, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestGrantResources(t *testing.T) {
+	payments := "https://payments.example/api"
+	accounts := "https://accounts.example/api"
+	other := "https://attacker.example/api"
+
+	cases := []struct {
+		title      string
+		grant      string
+		authorized []string
+		requested  []string
+		want       []string
+		wantErr    bool
+	}{
+		{title: "Authorized resources", grant: "requested", authorized: []string{payments, accounts}, want: []string{payments, accounts}},
+		{title: "Narrowed resources", grant: "requested", authorized: []string{payments, accounts}, requested: []string{accounts}, want: []string{accounts}},
+		{title: "Unauthorized resource", grant: "requested", authorized: []string{payments}, requested: []string{accounts}, wantErr: true},
+		{title: "Resources without authorization", grant: "requested", requested: []string{payments}, want: []string{payments}},
+		{title: "Relative resource", grant: "requested", requested: []string{"/api"}, wantErr: true},
+		{title: "Resource with fragment", grant: "requested", requested: []string{payments + "#frag"}, wantErr: true},
+		{title: "No resources", grant: "requested"},
+		{title: "Ignore", grant: "ignore", authorized: []string{payments}, requested: []string{accounts}},
+		{title: "Other resource", grant: "other", authorized: []string{payments}, requested: []string{payments}, want: []string{other}},
+		{title: "All resources", grant: "all", authorized: []string{payments}, requested: []string{accounts, payments}, want: []string{payments, accounts}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.ResourceConfig{Grant: tc.grant, OtherResource: other}
+			got, err := grantResources(&c, tc.authorized, tc.requested)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("grantResources() returned error %v, expected error %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("grantResources() returned %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResourceAudience(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+	payments := "https://payments.example/api"
+	accounts := "https://accounts.example/api"
+
+	cases := []struct {
+		title     string
+		grant     string
+		requested []string
+		wantCode  int
+		wantAud   any
+	}{
+		{title: "Authorized resources", grant: "requested", wantCode: http.StatusOK, wantAud: []any{payments, accounts}},
+		{title: "Narrowed resource", grant: "requested", requested: []string{accounts}, wantCode: http.StatusOK, wantAud: []any{accounts}},
+		{title: "Unauthorized resource", grant: "requested", requested: []string{"https://other.example/api"}, wantCode: http.StatusBadRequest},
+		{title: "Ignored resource", grant: "ignore", requested: []string{accounts}, wantCode: http.StatusOK, wantAud: []any{"https://idp.idp/oauth2/userinfo"}},
+		{title: "Other resource", grant: "other", requested: []string{accounts}, wantCode: http.StatusOK, wantAud: []any{"https://attacker.example/api"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.ResourceConfig.Grant = tc.grant
+			c.TokenAction.Respond.Parameters = []config.Parameter{
+				{ID: "access_token", Action: "custom", CustomKey: "signed_access_token", JSONType: "string"},
+			}
+			config.SetGlobalConfig(&c)
+
+			query := url.Values{
+				"client_id":     {"client"},
+				"redirect_uri":  {"https://client.example/cb"},
+				"response_type": {"code"},
+				"resource":      {payments, accounts},
+			}
+			req, err := http.NewRequest("GET", "https://idp.idp/oauth2/auth?"+query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+			location, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			form := url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")}, "resource": tc.requested}
+			code, content := postTokenRequest(t, form)
+			if code != tc.wantCode {
+				t.Fatalf("tokenHandler() returned %d rather than expected %d: %v", code, tc.wantCode, content)
+			}
+			if code != http.StatusOK {
+				if content["error"] != "invalid_target" {
+					t.Errorf("tokenHandler() returned error %v rather than invalid_target", content["error"])
+				}
+				return
+			}
+
+			_, claims, err := keys.DecodeToken(content["access_token"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(claims["aud"], tc.wantAud) {
+				t.Errorf("access token has aud %v, expected %v", claims["aud"], tc.wantAud)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"fmt"
+	"net/http"
+)
+
+// revokeHandler takes action for the Token Revocation endpoint based on config.
+func revokeHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().RevocationAction
+	input := getInputData(r)
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		revokeRespond(w, r, input, &action)
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// revokeRespond revokes the presented token unless configured to only pretend to.
+// Unknown tokens get a successful response as required by RFC 7009.
+func revokeRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput, c *config.RevocationAction) {
+	token := input.FormParams.Get("token")
+	if token == "" {
+		oauthErrorResponse(w, http.StatusBadRequest, "invalid_request", "missing token")
+		return
+	}
+
+	if !c.IgnoreRevocation {
+		if err := sessionmgmt.RevokeToken(token); err != nil {
+			logNotice(fmt.Sprintf("unknown token revoked: %v", err), r)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"bytes"
+	"customidp/config"
+	"customidp/keys"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRevokeHandler(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		title       string
+		revocation  config.RevocationAction
+		wantCode    int
+		wantRevoked bool
+	}{
+		{
+			title:       "Revoke",
+			revocation:  config.RevocationAction{Action: "respond"},
+			wantCode:    200,
+			wantRevoked: true,
+		},
+		{
+			title:      "Ignore revocation",
+			revocation: config.RevocationAction{Action: "respond", IgnoreRevocation: true},
+			wantCode:   200,
+		},
+		{
+			title:      "Error",
+			revocation: config.RevocationAction{Action: "error", Error: config.Error{ErrorCode: 503, ErrorContent: "unavailable"}},
+			wantCode:   503,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.RevocationAction = tc.revocation
+			config.SetGlobalConfig(&c)
+
+			code, issued := postTokenRequest(t, url.Values{"grant_type": {"client_credentials"}, "client_id": {"logoutclient"}})
+			if code != http.StatusOK {
+				t.Fatalf("tokenHandler() returned %d: %v", code, issued)
+			}
+			accessToken := issued["access_token"].(string)
+			refreshToken := issued["refresh_token"].(string)
+
+			for _, token := range []string{accessToken, refreshToken} {
+				form := url.Values{"token": {token}}
+				req, err := http.NewRequest("POST", "/oauth2/revoke", bytes.NewBufferString(form.Encode()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+				rr := httptest.NewRecorder()
+				http.HandlerFunc(revokeHandler).ServeHTTP(rr, req)
+				if rr.Code != tc.wantCode {
+					t.Fatalf("revokeHandler() returned %d rather than expected %d", rr.Code, tc.wantCode)
+				}
+			}
+
+			req, err := http.NewRequest("GET", "/oauth2/userinfo", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(userInfoHandler).ServeHTTP(rr, req)
+			if wantCode := map[bool]int{true: 401, false: 200}[tc.wantRevoked]; rr.Code != wantCode {
+				t.Errorf("userInfoHandler() returned %d rather than expected %d", rr.Code, wantCode)
+			}
+
+			var introspection map[string]any
+			if err := json.Unmarshal(postIntrospectRequest(t, accessToken, "").Body.Bytes(), &introspection); err != nil {
+				t.Fatal(err)
+			}
+			if introspection["active"] != !tc.wantRevoked {
+				t.Errorf("introspectHandler() returned active %v, expected %v", introspection["active"], !tc.wantRevoked)
+			}
+
+			code, refreshed := postTokenRequest(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+			if wantCode := map[bool]int{true: 400, false: 200}[tc.wantRevoked]; code != wantCode {
+				t.Errorf("tokenHandler() refresh returned %d rather than expected %d: %v", code, wantCode, refreshed)
+			}
+		})
+	}
+
+	t.Run("Missing token", func(t *testing.T) {
+		config.SetGlobalConfig(&config.DefaultConfig)
+		req, err := http.NewRequest("POST", "/oauth2/revoke", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(revokeHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("revokeHandler() returned %d rather than expected 400", rr.Code)
+		}
+	})
+}
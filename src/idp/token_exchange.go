@@ -0,0 +1,53 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/keys"
+	sessionmgmt "customidp/session"
+	"net/http"
+)
+
+// tokenExchangeGrantType is the RFC 8693 Token Exchange grant_type.
+const tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// getTokenExchange extracts the Token Exchange parameters from a parsed request.
+// Subject and actor tokens are decoded if they are JWTs, but never verified so
+// that templates can echo or tamper with any claims.
+func getTokenExchange(r *http.Request) *sessionmgmt.TokenExchange {
+	exchange := &sessionmgmt.TokenExchange{
+		SubjectToken:       r.Form.Get("subject_token"),
+		SubjectTokenType:   r.Form.Get("subject_token_type"),
+		ActorToken:         r.Form.Get("actor_token"),
+		ActorTokenType:     r.Form.Get("actor_token_type"),
+		RequestedTokenType: r.Form.Get("requested_token_type"),
+		Audience:           r.Form["audience"],
+		Resource:           r.Form["resource"],
+	}
+
+	if exchange.SubjectToken != "" {
+		if _, claims, err := keys.DecodeToken(exchange.SubjectToken); err == nil {
+			exchange.SubjectClaims = claims
+		}
+	}
+
+	if exchange.ActorToken != "" {
+		if _, claims, err := keys.DecodeToken(exchange.ActorToken); err == nil {
+			exchange.ActorClaims = claims
+		}
+	}
+
+	return exchange
+}
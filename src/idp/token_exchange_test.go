@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"customidp/keys"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+func TestTokenExchange(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+	config.SetGlobalConfig(&config.DefaultConfig)
+
+	makeToken := func(sub string, act any) string {
+		token := jwt.New()
+		token.Set("sub", sub)
+		if act != nil {
+			token.Set("act", act)
+		}
+		signed, err := keys.SignToken("RS256", token, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	cases := []struct {
+		title      string
+		form       url.Values
+		wantCode   int
+		wantClaims map[string]any
+	}{
+		{
+			title: "Delegation",
+			form: url.Values{
+				"subject_token":      {makeToken("user", nil)},
+				"subject_token_type": {"urn:ietf:params:oauth:token-type:jwt"},
+				"actor_token":        {makeToken("service", nil)},
+				"actor_token_type":   {"urn:ietf:params:oauth:token-type:jwt"},
+				"audience":           {"https://backend.example", "https://other.example"},
+			},
+			wantCode: 200,
+			wantClaims: map[string]any{
+				"sub": "user",
+				"aud": []string{"https://backend.example"},
+				"act": map[string]any{"sub": "service"},
+			},
+		},
+		{
+			title: "Delegation chain",
+			form: url.Values{
+				"subject_token":      {makeToken("user", map[string]any{"sub": "frontend"})},
+				"subject_token_type": {"urn:ietf:params:oauth:token-type:jwt"},
+				"actor_token":        {makeToken(`service "backend"`, nil)},
+				"actor_token_type":   {"urn:ietf:params:oauth:token-type:jwt"},
+			},
+			wantCode: 200,
+			wantClaims: map[string]any{
+				"sub": "user",
+				"act": map[string]any{"sub": `service "backend"`, "act": map[string]any{"sub": "frontend"}},
+			},
+		},
+		{
+			title: "Impersonation",
+			form: url.Values{
+				"subject_token":      {makeToken("user", nil)},
+				"subject_token_type": {"urn:ietf:params:oauth:token-type:jwt"},
+			},
+			wantCode: 200,
+			wantClaims: map[string]any{
+				"sub": "user",
+				"aud": nil,
+				"act": nil,
+			},
+		},
+		{
+			title:    "Missing subject token",
+			form:     url.Values{},
+			wantCode: 400,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			tc.form.Set("grant_type", tokenExchangeGrantType)
+			gotCode, results := postTokenRequest(t, tc.form)
+			if gotCode != tc.wantCode {
+				t.Fatalf("tokenHandler() returned %d rather than expected %d", gotCode, tc.wantCode)
+			}
+
+			if gotCode != 200 {
+				return
+			}
+
+			if results["issued_token_type"] != "urn:ietf:params:oauth:token-type:jwt" {
+				t.Errorf("tokenHandler() returned unexpected issued_token_type %v", results["issued_token_type"])
+			}
+
+			issued, err := jwt.ParseString(results["access_token"].(string))
+			if err != nil {
+				t.Fatalf("tokenHandler() returned unparsable access_token: %v", err)
+			}
+
+			for claim, want := range tc.wantClaims {
+				got, _ := issued.Get(claim)
+				if want == nil && got == nil {
+					continue
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("claim %q is %#v, expected %#v", claim, got, want)
+				}
+			}
+		})
+	}
+}
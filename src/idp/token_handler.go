@@ -17,20 +17,61 @@ package idp
 import (
 	"customidp/config"
 	sessionmgmt "customidp/session"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // tokenHandler takes action for the Token Endpoint based on config.
 func tokenHandler(w http.ResponseWriter, r *http.Request) {
 	// OAuth Spec says clients must use POST, however we won't enforce that
 	// here. We will just log the method along with other request info.
-	action := config.GetGlobalConfig().TokenAction
 	input := getInputData(r)
+	c := config.GetGlobalConfig()
+	action := c.TokenAction.ForGrantType(input.FormParams.Get("grant_type"))
 	addRequestLogEntry(input, action.Action)
 
 	switch action.Action {
 	case "respond":
-		tokenRespond(w, input)
+		if err := authenticateClient(c, r, input); err != nil {
+			logNotice(fmt.Sprintf("client authentication failed: %v", err), r)
+			if _, _, ok := r.BasicAuth(); ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			}
+			oauthErrorResponse(w, http.StatusUnauthorized, "invalid_client", err.Error())
+			return
+		}
+		if input.Headers.Get("DPoP") != "" || c.DPoPConfig.RequireProof {
+			if err := checkDPoPProof(&c.DPoPConfig, input, ""); err != nil {
+				logNotice(fmt.Sprintf("DPoP proof rejected: %v", err), r)
+				dpopTokenError(w, &c.DPoPConfig, input, err)
+				return
+			}
+		}
+		if _, err := sessionmgmt.ParseAuthorizationDetails(input.FormParams.Get("authorization_details")); err != nil {
+			oauthErrorResponse(w, http.StatusBadRequest, "invalid_authorization_details", err.Error())
+			return
+		}
+		resources, err := grantResources(&c.ResourceConfig, input.Session.Resources, input.FormParams["resource"])
+		if err != nil {
+			oauthErrorResponse(w, http.StatusBadRequest, "invalid_target", err.Error())
+			return
+		}
+		input.Resources = resources
+		// Other grants load the session of the original code, but have no code_verifier.
+		if input.FormParams.Get("grant_type") == "authorization_code" {
+			if err := checkPKCE(action.PKCEMode, input); err != nil {
+				logNotice(fmt.Sprintf("PKCE validation failed: %v", err), r)
+				oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", err.Error())
+				return
+			}
+		}
+		tokenRespond(w, r, input, &action)
+	case "forward":
+		tokenForward(w, r, input, &action.Forward)
 	case "error":
 		errorResponse(w, r, &action.Error)
 	case "block":
@@ -41,9 +82,187 @@ func tokenHandler(w http.ResponseWriter, r *http.Request) {
 // tokenRespond responds with jsonContent based on configuration.
 // The "signed_token_id" custom method can be used to create valid
 // signed tokens.
-func tokenRespond(w http.ResponseWriter, input *sessionmgmt.RequestInput) {
-	c := config.GetGlobalConfig().TokenAction.Respond
+// Refresh token grants are checked against the refresh token lifecycle config.
+func tokenRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput, action *config.TokenAction) {
+	var presented *sessionmgmt.Token
+	switch input.FormParams.Get("grant_type") {
+	case "refresh_token":
+		var err error
+		presented, err = checkRefreshToken(&action.RefreshToken, input)
+		if err != nil {
+			logNotice(fmt.Sprintf("refresh token rejected: %v", err), r)
+			oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", err.Error())
+			return
+		}
+	case deviceCodeGrantType:
+		polling := config.GetGlobalConfig().DeviceAction.Polling
+		if errCode := pollDeviceCode(&polling, input); errCode != "" {
+			oauthErrorResponse(w, http.StatusBadRequest, errCode, "")
+			return
+		}
+	case tokenExchangeGrantType:
+		if input.TokenExchange == nil || input.TokenExchange.SubjectToken == "" || input.TokenExchange.SubjectTokenType == "" {
+			oauthErrorResponse(w, http.StatusBadRequest, "invalid_request", "missing subject_token or subject_token_type")
+			return
+		}
+	case jwtBearerGrantType:
+		if err := checkAssertion(&action.JWTBearer, input); err != nil {
+			logNotice(fmt.Sprintf("assertion rejected: %v", err), r)
+			oauthErrorResponse(w, http.StatusBadRequest, "invalid_grant", err.Error())
+			return
+		}
+	case cibaGrantType:
+		if errCode := pollCIBA(input); errCode != "" {
+			oauthErrorResponse(w, http.StatusBadRequest, errCode, "")
+			return
+		}
+	}
+
+	content, err := getJSONContent(input, action.Respond.Parameters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Tokens requested with a DPoP proof are bound to its key.
+	if input.DPoP != nil && !config.GetGlobalConfig().DPoPConfig.IssueBearer {
+		content["token_type"] = "DPoP"
+	}
+
+	recordRefreshToken(&action.RefreshToken, input, presented, content)
+	recordAccessToken(input, content)
+
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Pragma", "no-cache")
-	jsonResponse(w, input, c.Parameters)
+	writeJSON(w, http.StatusOK, content)
+}
+
+// recordAccessToken tracks an access token issued in the response content.
+func recordAccessToken(input *sessionmgmt.RequestInput, content map[string]any) {
+	issued, ok := content["access_token"].(string)
+	if !ok || issued == "" {
+		return
+	}
+
+	token := newIssuedToken(input, issued, "access_token", content)
+	if expiresIn, ok := content["expires_in"].(int); ok {
+		token.ExpiresAt = input.Time.Add(time.Duration(expiresIn) * time.Second)
+	}
+	if content["token_type"] == "DPoP" && input.DPoP != nil {
+		token.JKT = input.DPoP.JKT
+	}
+	token.X5T = input.CertificateThumbprint
+	sessionmgmt.AddToken(token)
+}
+
+// newIssuedToken creates the record of a token issued in the response content.
+// The client and scope are taken from the response, the request or the session.
+func newIssuedToken(input *sessionmgmt.RequestInput, value string, tokenType string, content map[string]any) sessionmgmt.Token {
+	token := sessionmgmt.Token{
+		Value:    value,
+		Type:     tokenType,
+		ClientID: getClientID(input),
+		Scope:    input.FormParams.Get("scope"),
+		IssuedAt: input.Time,
+	}
+	if input.Session != nil {
+		token.Session = *input.Session
+		if token.ClientID == "" {
+			token.ClientID = input.Session.ClientID
+		}
+		if token.Scope == "" {
+			token.Scope = input.Session.Scope
+		}
+	}
+	if scope, ok := content["scope"].(string); ok {
+		token.Scope = scope
+	}
+	return token
+}
+
+// tokenForward relays the token request to the configured upstream token endpoint
+// and returns the upstream JSON response modified by the configured parameters.
+func tokenForward(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput, c *config.TokenForward) {
+	if c.Target == "" {
+		http.Error(w, "No upstream token endpoint configured", http.StatusInternalServerError)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.Target, strings.NewReader(input.FormParams.Encode()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create upstream request %v", err), http.StatusInternalServerError)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		logError(fmt.Sprintf("upstream token request failed: %v", err), r)
+		http.Error(w, fmt.Sprintf("Upstream request failed %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read upstream response %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	// Relay anything that isn't a JSON object as is, there is nothing to modify.
+	upstream := map[string]any{}
+	if err := json.Unmarshal(body, &upstream); err != nil {
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		w.Write(body)
+		return
+	}
+
+	input.Upstream = upstream
+	content, err := getForwardParams(input, c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp.StatusCode, content)
+}
+
+// getForwardParams builds the forwarded response from the upstream response and config.
+// Upstream values are first handled by the default action, then configured parameters
+// replace them. Passthrough parameters keep the upstream value with its original type.
+func getForwardParams(input *sessionmgmt.RequestInput, c *config.TokenForward) (map[string]any, error) {
+	content := map[string]any{}
+	if c.DefaultParamAction == "passthrough" {
+		for id, val := range input.Upstream {
+			content[id] = val
+		}
+	}
+
+	for _, configParam := range c.Parameters {
+		delete(content, configParam.ID)
+		if configParam.Action == "passthrough" {
+			if val, ok := input.Upstream[configParam.ID]; ok {
+				content[configParam.ID] = val
+			}
+			continue
+		}
+
+		jsonVal, err := configParam.GetJSON(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if jsonVal != nil {
+			content[configParam.ID] = jsonVal
+		}
+	}
+	return content, nil
 }
@@ -17,6 +17,7 @@ package idp
 import (
 	"bytes"
 	"customidp/config"
+	"customidp/keys"
 	"customidp/session"
 	"encoding/json"
 	"net/http"
@@ -65,6 +66,21 @@ func TestTokenHandler(t *testing.T) {
 			},
 			wantCode: 404,
 		},
+		{
+			title: "PKCE enforced",
+			config: &config.Config{
+				TokenAction: config.TokenAction{
+					Action:   "respond",
+					PKCEMode: "enforce",
+					Respond: config.TokenRespond{
+						Parameters: []config.Parameter{
+							{ID: "access_token", Action: "random", JSONType: "string"},
+						},
+					},
+				},
+			},
+			wantCode: 400,
+		},
 		{
 			title: "Use session state",
 			config: &config.Config{
@@ -92,13 +108,17 @@ func TestTokenHandler(t *testing.T) {
 				config.SetGlobalConfig(&config.DefaultConfig)
 			}
 
-			session.CreateSession(&session.RequestInput{}, url.Values{"code": []string{"randomval"}})
+			session.CreateSession(
+				&session.RequestInput{URLParams: url.Values{"code_challenge": {"challenge"}, "code_challenge_method": {"S256"}}},
+				url.Values{"code": []string{"randomval"}})
 
-			form := `code=randomval&
-			client_id=your_client_id&
-			client_secret=your_client_secret&
-			redirect_uri=https%3A//idpclient.idp/code&
-			grant_type=authorization_code`
+			form := url.Values{
+				"code":          {"randomval"},
+				"client_id":     {"your_client_id"},
+				"client_secret": {"your_client_secret"},
+				"redirect_uri":  {"https://idpclient.idp/code"},
+				"grant_type":    {"authorization_code"},
+			}.Encode()
 
 			req, err := http.NewRequest("POST", "", bytes.NewBuffer([]byte(form)))
 			if err != nil {
@@ -138,3 +158,251 @@ func TestTokenHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenHandlerForward(t *testing.T) {
+	var gotForm url.Values
+	var gotAuth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		gotForm = r.PostForm
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"upstreamaccess","id_token":"upstreamid","refresh_token":"upstreamrefresh","expires_in":3600}`))
+	}))
+	defer upstream.Close()
+
+	cases := []struct {
+		title       string
+		forward     config.TokenForward
+		wantResults map[string]any
+	}{
+		{
+			title: "Passthrough upstream response",
+			forward: config.TokenForward{
+				DefaultParamAction: "passthrough",
+			},
+			wantResults: map[string]any{
+				"access_token":  "upstreamaccess",
+				"id_token":      "upstreamid",
+				"refresh_token": "upstreamrefresh",
+				"expires_in":    float64(3600),
+			},
+		},
+		{
+			title: "Modify upstream response",
+			forward: config.TokenForward{
+				DefaultParamAction: "passthrough",
+				Parameters: []config.Parameter{
+					{ID: "id_token", Action: "set", Values: []string{"swapped-{{index .Upstream \"access_token\"}}"}, JSONType: "string"},
+					{ID: "refresh_token", Action: "omit"},
+				},
+			},
+			wantResults: map[string]any{
+				"access_token": "upstreamaccess",
+				"id_token":     "swapped-upstreamaccess",
+				"expires_in":   float64(3600),
+			},
+		},
+		{
+			title: "Omit by default",
+			forward: config.TokenForward{
+				DefaultParamAction: "omit",
+				Parameters: []config.Parameter{
+					{ID: "access_token", Action: "passthrough"},
+					{ID: "scope", Action: "passthrough"},
+				},
+			},
+			wantResults: map[string]any{
+				"access_token": "upstreamaccess",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			tc.forward.Target = upstream.URL
+			config.SetGlobalConfig(&config.Config{
+				TokenAction: config.TokenAction{
+					Action:  "forward",
+					Forward: tc.forward,
+				},
+			})
+
+			form := url.Values{"code": {"upstreamcode"}, "grant_type": {"authorization_code"}}
+			req, err := http.NewRequest("POST", "", bytes.NewBufferString(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth("client", "secret")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(tokenHandler).ServeHTTP(rr, req)
+
+			if rr.Code != 200 {
+				t.Fatalf("tokenHandler() returned %d rather than expected 200", rr.Code)
+			}
+
+			if !reflect.DeepEqual(form, gotForm) {
+				t.Errorf("upstream received form %v, expected %v", gotForm, form)
+			}
+
+			if gotAuth != req.Header.Get("Authorization") {
+				t.Errorf("upstream received Authorization %q, expected %q", gotAuth, req.Header.Get("Authorization"))
+			}
+
+			var gotResults map[string]any
+			if err = json.Unmarshal(rr.Body.Bytes(), &gotResults); err != nil {
+				t.Fatalf("Failed to parse json data returned from tokenHandler() %v", err)
+			}
+
+			if !reflect.DeepEqual(tc.wantResults, gotResults) {
+				t.Fatalf("tokenHandler() expected %v, got %v", tc.wantResults, gotResults)
+			}
+		})
+	}
+}
+
+func TestTokenHandlerGrantTypes(t *testing.T) {
+	config.SetGlobalConfig(&config.Config{
+		TokenAction: config.TokenAction{
+			Action: "respond",
+			Respond: config.TokenRespond{
+				Parameters: []config.Parameter{
+					{ID: "access_token", Action: "set", Values: []string{"default"}, JSONType: "string"},
+				},
+			},
+			GrantTypes: []config.GrantTypeAction{
+				{
+					GrantType: "client_credentials",
+					Action:    "respond",
+					Respond: config.TokenRespond{
+						Parameters: []config.Parameter{
+							{ID: "access_token", Action: "set", Values: []string{"machine"}, JSONType: "string"},
+						},
+					},
+				},
+				{
+					GrantType: "password",
+					Action:    "error",
+					Error:     config.Error{ErrorCode: 403, ErrorContent: "Forbidden"},
+				},
+			},
+		},
+	})
+
+	cases := []struct {
+		grantType  string
+		wantCode   int
+		wantAccess string
+	}{
+		{grantType: "authorization_code", wantCode: 200, wantAccess: "default"},
+		{grantType: "client_credentials", wantCode: 200, wantAccess: "machine"},
+		{grantType: "password", wantCode: 403},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.grantType, func(t *testing.T) {
+			form := url.Values{"grant_type": {tc.grantType}}
+			req, err := http.NewRequest("POST", "", bytes.NewBufferString(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(tokenHandler).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("tokenHandler() returned %d rather than expected %d", rr.Code, tc.wantCode)
+			}
+
+			if rr.Code == 200 {
+				var gotResults map[string]any
+				if err = json.Unmarshal(rr.Body.Bytes(), &gotResults); err != nil {
+					t.Fatalf("Failed to parse json data returned from tokenHandler() %v", err)
+				}
+
+				if gotResults["access_token"] != tc.wantAccess {
+					t.Errorf("tokenHandler() returned access_token %v, expected %q", gotResults["access_token"], tc.wantAccess)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenHandlerAuthorizationDetails(t *testing.T) {
+	if err := keys.SetupKeys(); err != nil {
+		t.Fatal(err)
+	}
+	details := `[{"type":"payment_initiation","actions":["initiate","status"]}]`
+
+	cases := []struct {
+		title string
+		grant string
+		want  any
+	}{
+		{title: "Echo", grant: "echo", want: []any{map[string]any{"type": "payment_initiation", "actions": []any{"initiate", "status"}}}},
+		{title: "Narrow", grant: "narrow", want: []any{map[string]any{"type": "payment_initiation", "actions": []any{"initiate"}}}},
+		{title: "Drop", grant: "drop"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.AuthorizationDetailsConfig.Grant = tc.grant
+			c.TokenAction.Respond.Parameters = []config.Parameter{
+				{ID: "access_token", Action: "custom", CustomKey: "signed_access_token", JSONType: "string"},
+				{ID: "authorization_details", Action: "custom", CustomKey: "granted_authorization_details", JSONType: "json"},
+			}
+			config.SetGlobalConfig(&c)
+
+			query := url.Values{
+				"client_id":             {"client"},
+				"redirect_uri":          {"https://client.example/cb"},
+				"response_type":         {"code"},
+				"authorization_details": {details},
+			}
+			req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+			location, err := url.Parse(rr.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, content := postTokenRequest(t, url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")}})
+			if code != http.StatusOK {
+				t.Fatalf("tokenHandler() returned %d: %v", code, content)
+			}
+			if !reflect.DeepEqual(content["authorization_details"], tc.want) {
+				t.Errorf("tokenHandler() returned authorization_details %v, expected %v", content["authorization_details"], tc.want)
+			}
+
+			_, claims, err := keys.DecodeToken(content["access_token"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(claims["authorization_details"], tc.want) {
+				t.Errorf("access token has authorization_details %v, expected %v", claims["authorization_details"], tc.want)
+			}
+		})
+	}
+
+	t.Run("Malformed", func(t *testing.T) {
+		config.SetGlobalConfig(&config.DefaultConfig)
+		query := url.Values{"redirect_uri": {"https://client.example/cb"}, "authorization_details": {`[{"actions":["initiate"]}]`}}
+		req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("authHandler() returned %d rather than expected 400", rr.Code)
+		}
+	})
+}
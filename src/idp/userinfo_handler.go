@@ -17,7 +17,9 @@ package idp
 import (
 	"customidp/config"
 	sessionmgmt "customidp/session"
+	"fmt"
 	"net/http"
+	"strings"
 )
 
 // userInfoHandler takes a token and returns associated user information.
@@ -28,7 +30,7 @@ func userInfoHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch action.Action {
 	case "respond":
-		userInfoRespond(w, input)
+		userInfoRespond(w, r, input)
 	case "error":
 		errorResponse(w, r, &action.Error)
 	case "block":
@@ -36,9 +38,33 @@ func userInfoHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// userInfoRespond responds with JSON content as configured.
-func userInfoRespond(w http.ResponseWriter, input *sessionmgmt.RequestInput) {
+// userInfoRespond responds with JSON content as configured. Revoked tokens are
+// rejected, DPoP-bound tokens need a DPoP proof with the bound key, and
+// certificate-bound tokens need the bound client certificate.
+func userInfoRespond(w http.ResponseWriter, r *http.Request, input *sessionmgmt.RequestInput) {
 	c := config.GetGlobalConfig().UserInfoAction.Respond
+	if input.Token != nil && input.Token.Revoked {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="token is revoked"`)
+		oauthErrorResponse(w, http.StatusUnauthorized, "invalid_token", "token is revoked")
+		return
+	}
+
+	dpop := config.GetGlobalConfig().DPoPConfig
+	accessToken, isDPoP := strings.CutPrefix(r.Header.Get("Authorization"), "DPoP ")
+	if err := checkDPoPBinding(&dpop, input, accessToken, isDPoP); err != nil {
+		logNotice(fmt.Sprintf("DPoP rejected: %v", err), r)
+		dpopResourceError(w, &dpop, input, err)
+		return
+	}
+
+	mtls := config.GetGlobalConfig().MTLSConfig
+	if err := checkCertificateBinding(&mtls, input); err != nil {
+		logNotice(fmt.Sprintf("Client certificate rejected: %v", err), r)
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="certificate does not match the access token"`)
+		oauthErrorResponse(w, http.StatusUnauthorized, "invalid_token", err.Error())
+		return
+	}
+
 	w.Header().Set("Cache-Control", "no-store")
 	w.Header().Set("Pragma", "no-cache")
 	jsonResponse(w, input, c.Parameters)
@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// webMessageTemplate posts the authorization response to the opening window or parent frame.
+var webMessageTemplate = template.Must(template.New("web_message").Parse(`<html>
+<head><title>Authorization Response</title></head>
+<body>
+<script nonce='{{.Nonce}}'>
+(function() {
+  var target = window.opener || window.parent;
+  target.postMessage({type: 'authorization_response', response: {{.Response}}}, {{.TargetOrigin}});
+  if (window.opener) {
+    window.close();
+  }
+})();
+</script>
+</body>
+</html>`))
+
+// writeWebMessage renders a page that sends the parameters to the target origin
+// with postMessage. Only the inline script is allowed to run.
+func writeWebMessage(w http.ResponseWriter, targetOrigin string, params url.Values) {
+	nonce, err := getNonce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make(map[string]string)
+	for id := range params {
+		response[id] = params.Get(id)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'", nonce))
+	webMessageTemplate.Execute(w, struct {
+		Response     map[string]string
+		TargetOrigin string
+		Nonce        string
+	}{response, targetOrigin, nonce})
+}
+
+// getTargetOrigin returns the postMessage target origin for the redirect URI.
+func getTargetOrigin(redirectURI string, c *config.WebMessage) string {
+	switch c.TargetOrigin {
+	case "wildcard":
+		return "*"
+	case "custom":
+		return c.Origin
+	}
+	return getOrigin(redirectURI)
+}
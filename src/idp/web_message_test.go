@@ -0,0 +1,83 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWebMessage(t *testing.T) {
+	cases := []struct {
+		title      string
+		webMessage config.WebMessage
+		wantOrigin string
+	}{
+		{
+			title:      "Redirect URI origin",
+			webMessage: config.WebMessage{TargetOrigin: "redirect_uri"},
+			wantOrigin: `"https://localhost:8080"`,
+		},
+		{
+			title:      "Wildcard origin",
+			webMessage: config.WebMessage{TargetOrigin: "wildcard"},
+			wantOrigin: `"*"`,
+		},
+		{
+			title:      "Wrong origin",
+			webMessage: config.WebMessage{TargetOrigin: "custom", Origin: "https://attacker.example"},
+			wantOrigin: `"https://attacker.example"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			c.AuthAction.Redirect.WebMessage = tc.webMessage
+			config.SetGlobalConfig(&c)
+
+			query := url.Values{
+				"redirect_uri":  {"https://localhost:8080/callback"},
+				"response_mode": {"web_message"},
+				"state":         {"wmstate"},
+			}
+			req, err := http.NewRequest("GET", "/oauth2/auth?"+query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(authHandler).ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("authHandler() returned %d rather than expected 200", rr.Code)
+			}
+
+			if csp := rr.Header().Get("Content-Security-Policy"); !strings.HasPrefix(csp, "default-src 'none'; script-src 'nonce-") {
+				t.Errorf("authHandler() returned unexpected CSP %q", csp)
+			}
+
+			body := rr.Body.String()
+			for _, want := range []string{`"state":"wmstate"`, `"code":"`, "}}, " + tc.wantOrigin + ");"} {
+				if !strings.Contains(body, want) {
+					t.Errorf("authHandler() web message page is missing %q: %s", want, body)
+				}
+			}
+		})
+	}
+}
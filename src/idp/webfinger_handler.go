@@ -0,0 +1,66 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	sessionmgmt "customidp/session"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// webFingerHandler answers WebFinger (RFC 7033) issuer discovery requests
+// based on config.
+func webFingerHandler(w http.ResponseWriter, r *http.Request) {
+	action := config.GetGlobalConfig().WebFingerAction
+	input := getInputData(r)
+	addRequestLogEntry(input, action.Action)
+
+	switch action.Action {
+	case "respond":
+		webFingerRespond(w, input, &action.Respond)
+	case "error":
+		errorResponse(w, r, &action.Error)
+	case "block":
+		blockResponse(w)
+	}
+}
+
+// webFingerRespond responds with a JSON Resource Descriptor of the configured
+// parameters. Requests without a resource are rejected.
+func webFingerRespond(w http.ResponseWriter, input *sessionmgmt.RequestInput, c *config.WebFingerRespond) {
+	if input.URLParams.Get("resource") == "" {
+		http.Error(w, "Missing resource parameter", http.StatusBadRequest)
+		return
+	}
+
+	content, err := getJSONContent(input, c.Parameters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := json.Marshal(content)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to marshal content %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jrd+json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idp
+
+import (
+	"customidp/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestWebFingerHandler(t *testing.T) {
+	issuerLink := func(href string) []any {
+		return []any{map[string]any{"rel": config.WebFingerIssuerRel, "href": href}}
+	}
+
+	cases := []struct {
+		title       string
+		action      func(c *config.WebFingerAction)
+		query       url.Values
+		wantCode    int
+		wantResults map[string]any
+	}{
+		{
+			title:       "Account resource",
+			query:       url.Values{"resource": {"acct:alice@idp.idp"}, "rel": {config.WebFingerIssuerRel}},
+			wantCode:    200,
+			wantResults: map[string]any{"subject": "acct:alice@idp.idp", "links": issuerLink("https://idp.idp")},
+		},
+		{
+			title:       "URL resource",
+			query:       url.Values{"resource": {"https://idp.idp/alice?tenant=a&x=b"}},
+			wantCode:    200,
+			wantResults: map[string]any{"subject": "https://idp.idp/alice?tenant=a&x=b", "links": issuerLink("https://idp.idp")},
+		},
+		{
+			title:       "Other rel",
+			query:       url.Values{"resource": {"acct:alice@idp.idp"}, "rel": {"http://webfinger.net/rel/avatar"}},
+			wantCode:    200,
+			wantResults: map[string]any{"subject": "acct:alice@idp.idp", "links": []any{}},
+		},
+		{
+			title:    "Attacker issuer",
+			action:   func(c *config.WebFingerAction) { c.Respond.AttackerResources = []string{"victim.example"} },
+			query:    url.Values{"resource": {"acct:bob@victim.example"}, "rel": {config.WebFingerIssuerRel}},
+			wantCode: 200,
+			wantResults: map[string]any{
+				"subject": "acct:bob@victim.example",
+				"links":   issuerLink("https://attacker.example"),
+			},
+		},
+		{
+			title:    "Missing resource",
+			query:    url.Values{"rel": {config.WebFingerIssuerRel}},
+			wantCode: 400,
+		},
+		{
+			title: "Error response",
+			action: func(c *config.WebFingerAction) {
+				c.Action = "error"
+				c.Error = config.Error{ErrorCode: 404, ErrorContent: "not found"}
+			},
+			query:    url.Values{"resource": {"acct:alice@idp.idp"}},
+			wantCode: 404,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			c := config.DefaultConfig
+			if tc.action != nil {
+				tc.action(&c.WebFingerAction)
+			}
+			config.SetGlobalConfig(&c)
+
+			req, err := http.NewRequest("GET", "https://idp.idp/.well-known/webfinger?"+tc.query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(webFingerHandler).ServeHTTP(rr, req)
+			if rr.Code != tc.wantCode {
+				t.Fatalf("webFingerHandler() returned %d rather than expected %d: %s", rr.Code, tc.wantCode, rr.Body.String())
+			}
+			if rr.Code != 200 {
+				return
+			}
+
+			if got := rr.Header().Get("Content-Type"); got != "application/jrd+json" {
+				t.Errorf("webFingerHandler() returned Content-Type %q", got)
+			}
+
+			var gotResults map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &gotResults); err != nil {
+				t.Fatalf("Failed to parse json data returned from webFingerHandler() %v", err)
+			}
+			if !reflect.DeepEqual(gotResults, tc.wantResults) {
+				t.Errorf("webFingerHandler() returned %v, expected %v", gotResults, tc.wantResults)
+			}
+		})
+	}
+}
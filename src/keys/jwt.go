@@ -15,13 +15,19 @@
 package keys
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"log"
 
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
 	"github.com/lestrrat-go/jwx/jwt"
 )
 
@@ -29,6 +35,11 @@ import (
 // Id wrongKey is true, it will use a valid key, but it won't be in the
 // IdP's set of JSON keys.
 func SignToken(alg string, token jwt.Token, wrongKey bool) (string, error) {
+	return SignTokenWithType(alg, token, wrongKey, "")
+}
+
+// SignTokenWithType signs a token like SignToken, setting the typ header if it is not empty.
+func SignTokenWithType(alg string, token jwt.Token, wrongKey bool, typ string) (string, error) {
 	var sigAlg jwa.SignatureAlgorithm
 	if err := sigAlg.Accept(alg); err != nil {
 		return "", fmt.Errorf("invalid algorithm %q: %s", alg, err)
@@ -49,7 +60,12 @@ func SignToken(alg string, token jwt.Token, wrongKey bool) (string, error) {
 		return "", fmt.Errorf("specified key %s not supported", alg)
 	}
 
-	signedBytes, err := jwt.Sign(token, sigAlg, key)
+	headers := jws.NewHeaders()
+	if typ != "" {
+		headers.Set(jws.TypeKey, typ)
+	}
+
+	signedBytes, err := jwt.Sign(token, sigAlg, key, jwt.WithHeaders(headers))
 	if err != nil {
 		log.Printf("failed to sign token: %s", err)
 		return "", fmt.Errorf("failed to sign token: %s", err)
@@ -59,6 +75,149 @@ func SignToken(alg string, token jwt.Token, wrongKey bool) (string, error) {
 	return signed, nil
 }
 
+// DecodeToken decodes a compact JWS without verifying its signature and returns the
+// protected header and claims.
+func DecodeToken(raw string) (map[string]any, map[string]any, error) {
+	msg, err := jws.ParseString(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse token: %s", err)
+	}
+
+	if len(msg.Signatures()) == 0 {
+		return nil, nil, fmt.Errorf("token has no signature header")
+	}
+
+	// Round trip the header through JSON so values are plain JSON types.
+	headerJSON, err := json.Marshal(msg.Signatures()[0].ProtectedHeaders())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read token header: %s", err)
+	}
+
+	header := map[string]any{}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, nil, fmt.Errorf("failed to read token header: %s", err)
+	}
+
+	claims := map[string]any{}
+	if err := json.Unmarshal(msg.Payload(), &claims); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse token claims: %s", err)
+	}
+
+	return header, claims, nil
+}
+
+// VerifyWithKeySet verifies a compact JWS with any signing key in a JSON Key Set.
+// The algorithm is taken from the token header, the none algorithm is never accepted.
+func VerifyWithKeySet(raw string, jwksJSON string) error {
+	header, _, err := DecodeToken(raw)
+	if err != nil {
+		return err
+	}
+
+	alg, _ := header["alg"].(string)
+	if alg == "" || alg == string(jwa.NoSignature) {
+		return fmt.Errorf("token is not signed")
+	}
+
+	set, err := jwk.ParseString(jwksJSON)
+	if err != nil {
+		return fmt.Errorf("failed to parse key set: %s", err)
+	}
+
+	for i := 0; i < set.Len(); i++ {
+		key, ok := set.Get(i)
+		if !ok {
+			continue
+		}
+
+		if usage := key.KeyUsage(); usage != "" && usage != jwk.ForSignature.String() {
+			continue
+		}
+
+		if _, err := jws.Verify([]byte(raw), jwa.SignatureAlgorithm(alg), key); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to verify token with any key in the key set")
+}
+
+// JWKThumbprint returns the base64url encoded RFC 7638 SHA-256 thumbprint of a
+// public JSON Web Key. Private and symmetric keys are rejected.
+func JWKThumbprint(jwkJSON string) (string, error) {
+	key, err := jwk.ParseKey([]byte(jwkJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse key: %s", err)
+	}
+
+	switch key.(type) {
+	case jwk.RSAPrivateKey, jwk.ECDSAPrivateKey, jwk.OKPPrivateKey, jwk.SymmetricKey:
+		return "", fmt.Errorf("key is not a public key")
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute thumbprint: %s", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// KeySetContainsPublicKey reports whether a JSON Key Set holds the public key.
+func KeySetContainsPublicKey(jwksJSON string, pub crypto.PublicKey) (bool, error) {
+	set, err := jwk.ParseString(jwksJSON)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse key set: %s", err)
+	}
+
+	target, ok := pub.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return false, fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	for i := 0; i < set.Len(); i++ {
+		key, ok := set.Get(i)
+		if !ok {
+			continue
+		}
+
+		var raw any
+		if err := key.Raw(&raw); err == nil && target.Equal(raw) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EncryptToken encrypts a signed token as a nested JWT to the first encryption
+// key in a JSON Key Set.
+func EncryptToken(signed string, jwksJSON string, keyAlg string, contentAlg string) (string, error) {
+	set, err := jwk.ParseString(jwksJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse key set: %s", err)
+	}
+
+	var key jwk.Key
+	for i := 0; i < set.Len() && key == nil; i++ {
+		k, ok := set.Get(i)
+		if ok && (k.KeyUsage() == "" || k.KeyUsage() == jwk.ForEncryption.String()) {
+			key = k
+		}
+	}
+	if key == nil {
+		return "", fmt.Errorf("no encryption key in the key set")
+	}
+
+	headers := jwe.NewHeaders()
+	headers.Set(jwe.ContentTypeKey, "JWT")
+	encrypted, err := jwe.Encrypt([]byte(signed), jwa.KeyEncryptionAlgorithm(keyAlg), key,
+		jwa.ContentEncryptionAlgorithm(contentAlg), jwa.NoCompress, jwe.WithProtectedHeaders(headers))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt token: %s", err)
+	}
+
+	return string(encrypted), nil
+}
+
 // publicKeyToBytes gets a RSA Public key as a byte array.
 func publicKeyToBytes(pub *rsa.PublicKey) []byte {
 	pubASN1, err := x509.MarshalPKIXPublicKey(pub)
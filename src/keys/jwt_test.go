@@ -15,8 +15,13 @@
 package keys
 
 import (
+	"crypto/rsa"
+	"encoding/json"
 	"testing"
 
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
 )
 
@@ -69,3 +74,185 @@ func TestSignToken(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeToken(t *testing.T) {
+	if err := SetupKeys(); err != nil {
+		t.Fatalf("unexpected error from SetupKeys %v", err)
+	}
+
+	token := jwt.New()
+	token.Set("sub", "testsub")
+
+	for _, alg := range []string{"RS256", "ES256", "none"} {
+		signedToken, err := SignToken(alg, token, false)
+		if err != nil {
+			t.Fatalf("SignToken(%q) failed: %v", alg, err)
+		}
+
+		header, claims, err := DecodeToken(signedToken)
+		if err != nil {
+			t.Fatalf("DecodeToken() failed for %q: %v", alg, err)
+		}
+
+		if header["alg"] != alg || claims["sub"] != "testsub" {
+			t.Errorf("DecodeToken() returned unexpected header %v and claims %v", header, claims)
+		}
+	}
+
+	if _, _, err := DecodeToken("not a token"); err == nil {
+		t.Errorf("DecodeToken() expected an error for an invalid token")
+	}
+}
+
+func TestSignTokenWithType(t *testing.T) {
+	if err := SetupKeys(); err != nil {
+		t.Fatalf("unexpected error from SetupKeys %v", err)
+	}
+
+	token := jwt.New()
+	token.Set("sub", "testsub")
+
+	signedToken, err := SignTokenWithType("RS256", token, false, "logout+jwt")
+	if err != nil {
+		t.Fatalf("SignTokenWithType() failed: %v", err)
+	}
+
+	header, _, err := DecodeToken(signedToken)
+	if err != nil {
+		t.Fatalf("DecodeToken() failed: %v", err)
+	}
+
+	if header["typ"] != "logout+jwt" {
+		t.Errorf("SignTokenWithType() returned typ %v, expected logout+jwt", header["typ"])
+	}
+}
+
+func TestVerifyWithKeySet(t *testing.T) {
+	if err := SetupKeys(); err != nil {
+		t.Fatalf("unexpected error from SetupKeys %v", err)
+	}
+
+	jwks, err := GetJSONKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.New()
+	token.Set("sub", "testsub")
+
+	cases := []struct {
+		title    string
+		alg      string
+		wrongKey bool
+		wantErr  bool
+	}{
+		{title: "RSA", alg: "RS256"},
+		{title: "ECDSA", alg: "ES256"},
+		{title: "Wrong key", alg: "RS256", wrongKey: true, wantErr: true},
+		{title: "Unsigned", alg: "none", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			signedToken, err := SignToken(tc.alg, token, tc.wrongKey)
+			if err != nil {
+				t.Fatalf("SignToken(%q) failed: %v", tc.alg, err)
+			}
+
+			err = VerifyWithKeySet(signedToken, jwks)
+			if tc.wantErr && err == nil {
+				t.Errorf("VerifyWithKeySet() expected an error but got none")
+			} else if !tc.wantErr && err != nil {
+				t.Errorf("VerifyWithKeySet() returned unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 7638 Section 3.1 example key and thumbprint.
+	public := `{"kty":"RSA","e":"AQAB","alg":"RS256","kid":"2011-04-29","n":"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}`
+
+	got, err := JWKThumbprint(public)
+	if err != nil {
+		t.Fatalf("JWKThumbprint() failed: %v", err)
+	}
+	if got != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("JWKThumbprint() returned %q", got)
+	}
+
+	if _, err := JWKThumbprint(`{"kty":"oct","k":"c2VjcmV0"}`); err == nil {
+		t.Errorf("JWKThumbprint() expected an error for a symmetric key")
+	}
+	if _, err := JWKThumbprint("not a key"); err == nil {
+		t.Errorf("JWKThumbprint() expected an error for an invalid key")
+	}
+}
+
+func TestKeySetContainsPublicKey(t *testing.T) {
+	if err := SetupKeys(); err != nil {
+		t.Fatalf("unexpected error from SetupKeys %v", err)
+	}
+
+	privKey := GetKey("RSA", false).Raw.(*rsa.PrivateKey)
+	pubKey, err := jwk.New(&privKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := jwk.NewSet()
+	set.Add(pubKey)
+	jwksBytes, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := KeySetContainsPublicKey(string(jwksBytes), &privKey.PublicKey); err != nil || !ok {
+		t.Errorf("KeySetContainsPublicKey() = %v, %v, expected the key to be found", ok, err)
+	}
+
+	wrongKey := GetKey("RSA", true).Raw.(*rsa.PrivateKey)
+	if ok, err := KeySetContainsPublicKey(string(jwksBytes), &wrongKey.PublicKey); err != nil || ok {
+		t.Errorf("KeySetContainsPublicKey() = %v, %v, expected the wrong key not to be found", ok, err)
+	}
+
+	if _, err := KeySetContainsPublicKey("not a key set", &privKey.PublicKey); err == nil {
+		t.Errorf("KeySetContainsPublicKey() expected an error for an invalid key set")
+	}
+}
+
+func TestEncryptToken(t *testing.T) {
+	if err := SetupKeys(); err != nil {
+		t.Fatalf("unexpected error from SetupKeys %v", err)
+	}
+
+	privKey := GetKey("RSA", false).Raw.(*rsa.PrivateKey)
+	pubKey, err := jwk.New(&privKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := jwk.NewSet()
+	set.Add(pubKey)
+	jwksBytes, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := string(jwksBytes)
+
+	encrypted, err := EncryptToken("header.payload.signature", jwks, "RSA-OAEP-256", "A128GCM")
+	if err != nil {
+		t.Fatalf("EncryptToken() failed: %v", err)
+	}
+
+	decrypted, err := jwe.Decrypt([]byte(encrypted), jwa.RSA_OAEP_256, privKey)
+	if err != nil {
+		t.Fatalf("jwe.Decrypt() failed: %v", err)
+	}
+
+	if string(decrypted) != "header.payload.signature" {
+		t.Errorf("EncryptToken() encrypted %q rather than the signed token", decrypted)
+	}
+
+	if _, err := EncryptToken("header.payload.signature", jwks, "RSA-OAEP-256", "bogus"); err == nil {
+		t.Errorf("EncryptToken() expected an error for an unknown content encryption algorithm")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ParseAuthorizationDetails parses an authorization_details parameter of Rich
+// Authorization Requests (RFC 9396). It must be a JSON array of objects that
+// each have a type. An empty parameter has no details.
+func ParseAuthorizationDetails(raw string) ([]map[string]any, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var details []map[string]any
+	if err := json.Unmarshal([]byte(raw), &details); err != nil {
+		return nil, fmt.Errorf("authorization_details is not a JSON array of objects: %v", err)
+	}
+
+	for i, detail := range details {
+		if typ, _ := detail["type"].(string); typ == "" {
+			return nil, fmt.Errorf("authorization_details entry %d has no type", i)
+		}
+	}
+	return details, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAuthorizationDetails(t *testing.T) {
+	cases := []struct {
+		title   string
+		raw     string
+		want    []map[string]any
+		wantErr bool
+	}{
+		{title: "Empty", raw: ""},
+		{
+			title: "Details",
+			raw:   `[{"type":"payment_initiation","actions":["initiate"]},{"type":"account_information"}]`,
+			want: []map[string]any{
+				{"type": "payment_initiation", "actions": []any{"initiate"}},
+				{"type": "account_information"},
+			},
+		},
+		{title: "Not an array", raw: `{"type":"payment_initiation"}`, wantErr: true},
+		{title: "Missing type", raw: `[{"actions":["initiate"]}]`, wantErr: true},
+		{title: "Malformed", raw: `[{"type":`, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := ParseAuthorizationDetails(tc.raw)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseAuthorizationDetails() returned error %v, expected error %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseAuthorizationDetails() returned %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BackchannelAuthorization tracks a CIBA backchannel authentication request.
+type BackchannelAuthorization struct {
+	// The auth_req_id returned to the client.
+	AuthReqID string
+
+	// One of pending, approved, denied or issued.
+	Status string
+
+	// The client's client_notification_token for ping and push modes.
+	NotificationToken string
+
+	// Time the auth_req_id expires, zero if it doesn't.
+	ExpiresAt time.Time
+
+	// Session state for Token endpoint calls and notifications.
+	Session Session
+}
+
+// Global map for tracking backchannel authorizations by auth_req_id.
+var backchannels map[string]BackchannelAuthorization
+var backchannelsMutex sync.Mutex
+
+// AddBackchannelAuthorization adds or replaces a backchannel authorization keyed by auth_req_id.
+func AddBackchannelAuthorization(auth BackchannelAuthorization) {
+	backchannelsMutex.Lock()
+	defer backchannelsMutex.Unlock()
+	if backchannels == nil {
+		backchannels = make(map[string]BackchannelAuthorization)
+	}
+	backchannels[auth.AuthReqID] = auth
+}
+
+// GetBackchannelAuthorization returns the BackchannelAuthorization by auth_req_id.
+func GetBackchannelAuthorization(authReqID string) (BackchannelAuthorization, error) {
+	backchannelsMutex.Lock()
+	defer backchannelsMutex.Unlock()
+	auth, ok := backchannels[authReqID]
+	if !ok {
+		return BackchannelAuthorization{}, fmt.Errorf("no backchannel authorization found")
+	}
+
+	return auth, nil
+}
+
+// UpdateBackchannelAuthorization calls update with the backchannel authorization of
+// the auth_req_id and stores the result unless update returns an error. The lock is
+// held throughout so a check and change of the status can't race with another request.
+func UpdateBackchannelAuthorization(authReqID string, update func(*BackchannelAuthorization) error) error {
+	backchannelsMutex.Lock()
+	defer backchannelsMutex.Unlock()
+	auth, ok := backchannels[authReqID]
+	if !ok {
+		return fmt.Errorf("no backchannel authorization found")
+	}
+
+	if err := update(&auth); err != nil {
+		return err
+	}
+	backchannels[authReqID] = auth
+	return nil
+}
+
+// SetBackchannelStatus sets the status of a pending backchannel authorization and
+// returns the updated authorization.
+func SetBackchannelStatus(authReqID string, status string) (BackchannelAuthorization, error) {
+	backchannelsMutex.Lock()
+	defer backchannelsMutex.Unlock()
+	auth, ok := backchannels[authReqID]
+	if !ok {
+		return BackchannelAuthorization{}, fmt.Errorf("no backchannel authorization found")
+	}
+
+	if auth.Status != "pending" {
+		return BackchannelAuthorization{}, fmt.Errorf("backchannel authorization is already %s", auth.Status)
+	}
+	auth.Status = status
+	backchannels[authReqID] = auth
+	return auth, nil
+}
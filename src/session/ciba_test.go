@@ -0,0 +1,63 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBackchannelAuthorizationStorage(t *testing.T) {
+	AddBackchannelAuthorization(BackchannelAuthorization{AuthReqID: "authreq", Status: "pending", NotificationToken: "token"})
+
+	auth, err := SetBackchannelStatus("authreq", "approved")
+	if err != nil {
+		t.Fatalf("SetBackchannelStatus() failed with unexpected error: %v", err)
+	}
+
+	if auth.Status != "approved" || auth.NotificationToken != "token" {
+		t.Errorf("SetBackchannelStatus() returned unexpected authorization %v", auth)
+	}
+
+	if _, err := SetBackchannelStatus("authreq", "denied"); err == nil {
+		t.Errorf("SetBackchannelStatus() expected an error for an already approved request")
+	}
+
+	if err := UpdateBackchannelAuthorization("authreq", func(auth *BackchannelAuthorization) error {
+		auth.Status = "issued"
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateBackchannelAuthorization() failed with unexpected error: %v", err)
+	}
+
+	if err := UpdateBackchannelAuthorization("authreq", func(auth *BackchannelAuthorization) error {
+		auth.Status = "pending"
+		return fmt.Errorf("rejected")
+	}); err == nil {
+		t.Errorf("UpdateBackchannelAuthorization() expected the update error")
+	}
+
+	if auth, _ := GetBackchannelAuthorization("authreq"); auth.Status != "issued" {
+		t.Errorf("expected status issued, got %q", auth.Status)
+	}
+
+	if err := UpdateBackchannelAuthorization("missing", func(*BackchannelAuthorization) error { return nil }); err == nil {
+		t.Errorf("UpdateBackchannelAuthorization() expected an error for a missing auth_req_id")
+	}
+
+	if _, err := GetBackchannelAuthorization("missing"); err == nil {
+		t.Errorf("GetBackchannelAuthorization() expected an error for a missing auth_req_id")
+	}
+}
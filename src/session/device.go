@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeviceAuthorization tracks a Device Authorization Grant (RFC 8628) request.
+type DeviceAuthorization struct {
+	// The device_code the client polls the Token endpoint with.
+	DeviceCode string
+
+	// The user_code the tester enters on the verification page.
+	UserCode string
+
+	// One of pending, approved, denied or issued.
+	Status string
+
+	// Number of times the Token endpoint was polled.
+	Polls int
+
+	// Minimum polling interval the client was given.
+	Interval time.Duration
+
+	// Time of the last Token endpoint poll.
+	LastPoll time.Time
+
+	// Time the device_code expires, zero if it doesn't.
+	ExpiresAt time.Time
+
+	// Session state for Token endpoint calls.
+	Session Session
+}
+
+// Global map for tracking device authorizations by device code.
+var devices map[string]DeviceAuthorization
+var devicesMutex sync.Mutex
+
+// AddDeviceAuthorization adds or replaces a device authorization keyed by device code.
+func AddDeviceAuthorization(device DeviceAuthorization) {
+	devicesMutex.Lock()
+	defer devicesMutex.Unlock()
+	if devices == nil {
+		devices = make(map[string]DeviceAuthorization)
+	}
+	devices[device.DeviceCode] = device
+}
+
+// GetDeviceAuthorization returns the DeviceAuthorization by device code.
+func GetDeviceAuthorization(deviceCode string) (DeviceAuthorization, error) {
+	devicesMutex.Lock()
+	defer devicesMutex.Unlock()
+	device, ok := devices[deviceCode]
+	if !ok {
+		return DeviceAuthorization{}, fmt.Errorf("no device authorization found")
+	}
+
+	return device, nil
+}
+
+// UpdateDeviceAuthorization calls update with the device authorization of the device
+// code and stores the result, holding the lock so concurrent changes aren't lost.
+func UpdateDeviceAuthorization(deviceCode string, update func(*DeviceAuthorization)) error {
+	devicesMutex.Lock()
+	defer devicesMutex.Unlock()
+	device, ok := devices[deviceCode]
+	if !ok {
+		return fmt.Errorf("no device authorization found")
+	}
+
+	update(&device)
+	devices[deviceCode] = device
+	return nil
+}
+
+// SetDeviceStatus sets the status of a pending device authorization by user code.
+// User codes are compared ignoring case, spaces and dashes.
+func SetDeviceStatus(userCode string, status string) error {
+	devicesMutex.Lock()
+	defer devicesMutex.Unlock()
+	for deviceCode, device := range devices {
+		if normalizeUserCode(device.UserCode) == normalizeUserCode(userCode) {
+			if device.Status != "pending" {
+				return fmt.Errorf("device authorization is already %s", device.Status)
+			}
+			device.Status = status
+			devices[deviceCode] = device
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no device authorization found")
+}
+
+// normalizeUserCode removes formatting characters from a user code.
+func normalizeUserCode(userCode string) string {
+	userCode = strings.ReplaceAll(userCode, "-", "")
+	userCode = strings.ReplaceAll(userCode, " ", "")
+	return strings.ToUpper(userCode)
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"testing"
+)
+
+func TestDeviceAuthorizationStorage(t *testing.T) {
+	AddDeviceAuthorization(DeviceAuthorization{DeviceCode: "device", UserCode: "BCDF-GHJK", Status: "pending"})
+
+	if err := SetDeviceStatus("bcdfghjk", "approved"); err != nil {
+		t.Fatalf("SetDeviceStatus() failed with unexpected error: %v", err)
+	}
+
+	device, err := GetDeviceAuthorization("device")
+	if err != nil {
+		t.Fatalf("GetDeviceAuthorization() failed with unexpected error: %v", err)
+	}
+
+	if device.Status != "approved" {
+		t.Errorf("expected status approved, got %q", device.Status)
+	}
+
+	if err := UpdateDeviceAuthorization("device", func(device *DeviceAuthorization) { device.Polls++ }); err != nil {
+		t.Fatalf("UpdateDeviceAuthorization() failed with unexpected error: %v", err)
+	}
+
+	if device, _ = GetDeviceAuthorization("device"); device.Polls != 1 || device.Status != "approved" {
+		t.Errorf("expected 1 poll and status approved, got %d and %q", device.Polls, device.Status)
+	}
+
+	if err := UpdateDeviceAuthorization("missing", func(*DeviceAuthorization) {}); err == nil {
+		t.Errorf("UpdateDeviceAuthorization() expected an error for a missing device code")
+	}
+
+	if err := SetDeviceStatus("BCDF-GHJK", "denied"); err == nil {
+		t.Errorf("SetDeviceStatus() expected an error for an already approved device")
+	}
+
+	if err := SetDeviceStatus("missing", "denied"); err == nil {
+		t.Errorf("SetDeviceStatus() expected an error for a missing user code")
+	}
+
+	if _, err := GetDeviceAuthorization("missing"); err == nil {
+		t.Errorf("GetDeviceAuthorization() expected an error for a missing device code")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DPoPProof is a decoded DPoP proof (RFC 9449) from the DPoP header.
+type DPoPProof struct {
+	JWT
+
+	// The base64url SHA-256 thumbprint of the proof's public key, for cnf.jkt.
+	JKT string
+}
+
+// Global maps for tracking used DPoP proof jti values and issued nonces.
+var dpopJTIs map[string]time.Time
+var dpopNonces map[string]time.Time
+var dpopMutex sync.Mutex
+
+// UseDPoPJTI records a DPoP proof jti until it expires. It fails if the jti
+// was already used by an unexpired proof.
+func UseDPoPJTI(jti string, now time.Time, expiresAt time.Time) error {
+	dpopMutex.Lock()
+	defer dpopMutex.Unlock()
+	if dpopJTIs == nil {
+		dpopJTIs = make(map[string]time.Time)
+	}
+
+	if exp, ok := dpopJTIs[jti]; ok && now.Before(exp) {
+		return fmt.Errorf("DPoP proof jti was already used")
+	}
+	dpopJTIs[jti] = expiresAt
+	return nil
+}
+
+// AddDPoPNonce adds a server-provided DPoP nonce valid until it expires.
+func AddDPoPNonce(nonce string, expiresAt time.Time) {
+	dpopMutex.Lock()
+	defer dpopMutex.Unlock()
+	if dpopNonces == nil {
+		dpopNonces = make(map[string]time.Time)
+	}
+	dpopNonces[nonce] = expiresAt
+}
+
+// CheckDPoPNonce checks the nonce was issued and hasn't expired.
+func CheckDPoPNonce(nonce string, now time.Time) error {
+	dpopMutex.Lock()
+	defer dpopMutex.Unlock()
+	exp, ok := dpopNonces[nonce]
+	if !ok {
+		return fmt.Errorf("no DPoP nonce found")
+	}
+	if !now.Before(exp) {
+		return fmt.Errorf("DPoP nonce is expired")
+	}
+	return nil
+}
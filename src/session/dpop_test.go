@@ -0,0 +1,49 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDPoPJTIReplay(t *testing.T) {
+	now := time.Now()
+	if err := UseDPoPJTI("jti", now, now.Add(time.Minute)); err != nil {
+		t.Fatalf("UseDPoPJTI() failed with unexpected error: %v", err)
+	}
+	if err := UseDPoPJTI("jti", now, now.Add(time.Minute)); err == nil {
+		t.Errorf("UseDPoPJTI() expected an error for a replayed jti")
+	}
+	if err := UseDPoPJTI("jti", now.Add(2*time.Minute), now.Add(3*time.Minute)); err != nil {
+		t.Errorf("UseDPoPJTI() failed for a jti whose proof expired: %v", err)
+	}
+}
+
+func TestDPoPNonces(t *testing.T) {
+	now := time.Now()
+	AddDPoPNonce("fresh", now.Add(time.Minute))
+	AddDPoPNonce("stale", now.Add(-time.Minute))
+
+	if err := CheckDPoPNonce("fresh", now); err != nil {
+		t.Errorf("CheckDPoPNonce() failed with unexpected error: %v", err)
+	}
+	if err := CheckDPoPNonce("stale", now); err == nil {
+		t.Errorf("CheckDPoPNonce() expected an error for an expired nonce")
+	}
+	if err := CheckDPoPNonce("unknown", now); err == nil {
+		t.Errorf("CheckDPoPNonce() expected an error for an unknown nonce")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// PushedRequest tracks a Pushed Authorization Request (RFC 9126).
+type PushedRequest struct {
+	// The request_uri returned to the client.
+	RequestURI string
+
+	// The pushed authorization request parameters.
+	Params url.Values
+
+	// Number of times the request_uri was used at the Authorization endpoint.
+	Uses int
+
+	// Time the request_uri expires, zero if it doesn't.
+	ExpiresAt time.Time
+}
+
+// Global map for tracking pushed requests by request_uri.
+var pushedRequests map[string]PushedRequest
+var pushedRequestsMutex sync.Mutex
+
+// AddPushedRequest adds or replaces a pushed request keyed by request_uri.
+func AddPushedRequest(request PushedRequest) {
+	pushedRequestsMutex.Lock()
+	defer pushedRequestsMutex.Unlock()
+	if pushedRequests == nil {
+		pushedRequests = make(map[string]PushedRequest)
+	}
+	pushedRequests[request.RequestURI] = request
+}
+
+// UsePushedRequest returns the PushedRequest by request_uri, counting the use.
+// The returned Uses is the number of uses before this one.
+func UsePushedRequest(requestURI string) (PushedRequest, error) {
+	pushedRequestsMutex.Lock()
+	defer pushedRequestsMutex.Unlock()
+	request, ok := pushedRequests[requestURI]
+	if !ok {
+		return PushedRequest{}, fmt.Errorf("no pushed request found")
+	}
+
+	stored := request
+	stored.Uses++
+	pushedRequests[requestURI] = stored
+	return request, nil
+}
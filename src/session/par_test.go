@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPushedRequestStorage(t *testing.T) {
+	AddPushedRequest(PushedRequest{RequestURI: "urn:request", Params: url.Values{"client_id": {"client"}}})
+
+	for i := 0; i < 2; i++ {
+		request, err := UsePushedRequest("urn:request")
+		if err != nil {
+			t.Fatalf("UsePushedRequest() failed with unexpected error: %v", err)
+		}
+
+		if request.Uses != i {
+			t.Errorf("expected %d previous uses, got %d", i, request.Uses)
+		}
+
+		if request.Params.Get("client_id") != "client" {
+			t.Errorf("expected client_id client, got %q", request.Params.Get("client_id"))
+		}
+	}
+
+	if _, err := UsePushedRequest("missing"); err == nil {
+		t.Errorf("UsePushedRequest() expected an error for a missing request_uri")
+	}
+}
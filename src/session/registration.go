@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registration is a Dynamic Client Registration (RFC 7591) request.
+type Registration struct {
+	// The client metadata. Software statement claims take precedence over the
+	// JSON body when the software statement is used.
+	Metadata map[string]any
+
+	// The decoded software statement, if any.
+	SoftwareStatement *JWT
+}
+
+// RegisteredClient tracks a client registered at the registration endpoint.
+type RegisteredClient struct {
+	// The client_id returned to the client.
+	ClientID string
+
+	// The client_secret returned to the client, if any.
+	ClientSecret string
+
+	// The registration_access_token for the client configuration endpoint.
+	RegistrationAccessToken string
+
+	// The client information response returned on registration.
+	Metadata map[string]any
+
+	// Registration timestamp.
+	RegisteredAt time.Time
+}
+
+// Global map for tracking registered clients by client_id.
+var registeredClients map[string]RegisteredClient
+var registeredClientsMutex sync.Mutex
+
+// AddRegisteredClient adds or replaces a registered client keyed by client_id.
+func AddRegisteredClient(client RegisteredClient) {
+	registeredClientsMutex.Lock()
+	defer registeredClientsMutex.Unlock()
+	if registeredClients == nil {
+		registeredClients = make(map[string]RegisteredClient)
+	}
+	registeredClients[client.ClientID] = client
+}
+
+// GetRegisteredClient returns the RegisteredClient by client_id.
+func GetRegisteredClient(clientID string) (RegisteredClient, error) {
+	registeredClientsMutex.Lock()
+	defer registeredClientsMutex.Unlock()
+	client, ok := registeredClients[clientID]
+	if !ok {
+		return RegisteredClient{}, fmt.Errorf("no registered client found")
+	}
+	return client, nil
+}
+
+// GetRegisteredClients returns all registered clients ordered by client_id.
+func GetRegisteredClients() []RegisteredClient {
+	registeredClientsMutex.Lock()
+	defer registeredClientsMutex.Unlock()
+	clients := make([]RegisteredClient, 0, len(registeredClients))
+	for _, client := range registeredClients {
+		clients = append(clients, client)
+	}
+	slices.SortFunc(clients, func(a, b RegisteredClient) int { return strings.Compare(a.ClientID, b.ClientID) })
+	return clients
+}
+
+// DeleteRegisteredClient removes the registered client by client_id.
+func DeleteRegisteredClient(clientID string) error {
+	registeredClientsMutex.Lock()
+	defer registeredClientsMutex.Unlock()
+	if _, ok := registeredClients[clientID]; !ok {
+		return fmt.Errorf("no registered client found")
+	}
+	delete(registeredClients, clientID)
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import "testing"
+
+func TestRegisteredClientStorage(t *testing.T) {
+	AddRegisteredClient(RegisteredClient{ClientID: "dynamic", RegistrationAccessToken: "rat"})
+
+	client, err := GetRegisteredClient("dynamic")
+	if err != nil {
+		t.Fatalf("GetRegisteredClient() failed with unexpected error: %v", err)
+	}
+	if client.RegistrationAccessToken != "rat" {
+		t.Errorf("expected registration access token rat, got %q", client.RegistrationAccessToken)
+	}
+
+	if clients := GetRegisteredClients(); len(clients) != 1 || clients[0].ClientID != "dynamic" {
+		t.Errorf("GetRegisteredClients() returned %v, expected the dynamic client", clients)
+	}
+
+	if err := DeleteRegisteredClient("dynamic"); err != nil {
+		t.Fatalf("DeleteRegisteredClient() failed with unexpected error: %v", err)
+	}
+	if _, err := GetRegisteredClient("dynamic"); err == nil {
+		t.Errorf("GetRegisteredClient() expected an error for a deleted client")
+	}
+	if err := DeleteRegisteredClient("dynamic"); err == nil {
+		t.Errorf("DeleteRegisteredClient() expected an error for a missing client")
+	}
+}
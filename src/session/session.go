@@ -16,6 +16,9 @@
 package session
 
 import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -42,6 +45,18 @@ type Session struct {
 
 	// The client's redirect URI specified at the Authorization Endpoint.
 	RedirectURI         string
+
+	// The scope requested at the Authorization Endpoint.
+	Scope               string
+
+	// Random session ID used for the sid claim.
+	SessionID           string
+
+	// The authorization_details (RFC 9396) requested at the Authorization Endpoint.
+	AuthorizationDetails []map[string]any
+
+	// The resource indicators (RFC 8707) requested at the Authorization Endpoint.
+	Resources           []string
 }
 
 // RequestInput tracks request state and can be use in Parameter evaluation templates.
@@ -72,6 +87,91 @@ type RequestInput struct {
 
 	// Call timestamp.
 	Time       time.Time
+
+	// Upstream IdP's JSON response when the Token endpoint forwards the call.
+	Upstream   map[string]any
+
+	// Token Exchange (RFC 8693) request parameters for token exchange grants.
+	TokenExchange *TokenExchange
+
+	// The decoded assertion for JWT Bearer (RFC 7523) grants.
+	Assertion     *JWT
+
+	// The decoded request object of JWT-Secured Authorization Requests.
+	RequestObject *JWT
+
+	// The issued token presented in the token parameter, if it is known.
+	Token         *Token
+
+	// The Dynamic Client Registration request.
+	Registration  *Registration
+
+	// The client_id of the client authenticating or calling with a client_id parameter.
+	ClientID      string
+
+	// The decoded DPoP proof, if any.
+	DPoP          *DPoPProof
+
+	// The TLS client certificate chain, if the client presented one.
+	ClientCertificates    []*x509.Certificate
+
+	// The base64url SHA-256 thumbprint of the client certificate, for cnf.x5t#S256.
+	CertificateThumbprint string
+
+	// The resources (RFC 8707) granted for the request, for the token audience.
+	Resources             []string
+
+	// The issuer path inserted into a well-known metadata URL, if any.
+	IssuerPath            string
+}
+
+// ExpiresIn returns the Unix time the number of seconds after the call, for exp
+// claims shorter than the days .Time.AddDate can add.
+func (input *RequestInput) ExpiresIn(seconds int) int64 {
+	return input.Time.Add(time.Duration(seconds) * time.Second).Unix()
+}
+
+// JWT is a decoded JSON Web Token from a request.
+type JWT struct {
+	// The encoded token.
+	Raw    string
+
+	// The protected JOSE header.
+	Header map[string]any
+
+	// The token claims.
+	Claims map[string]any
+}
+
+// TokenExchange holds Token Exchange request parameters. Tokens that are JWTs are
+// decoded without signature verification.
+type TokenExchange struct {
+	// The token representing the party the new token is requested for.
+	SubjectToken       string
+
+	// The type identifier of the subject token.
+	SubjectTokenType   string
+
+	// Claims of the subject token if it is a JWT.
+	SubjectClaims      map[string]any
+
+	// The token representing the acting party, if any.
+	ActorToken         string
+
+	// The type identifier of the actor token.
+	ActorTokenType     string
+
+	// Claims of the actor token if it is a JWT.
+	ActorClaims        map[string]any
+
+	// The requested type of the issued token.
+	RequestedTokenType string
+
+	// Requested audience values.
+	Audience           []string
+
+	// Requested resource URIs.
+	Resource           []string
 }
 
 // Global map for tracking sessions.
@@ -79,13 +179,13 @@ var sessions map[string]Session
 var sessionsMutex sync.Mutex
 
 // CreateSession adds a new session and pulls session state from the Request input.
-func CreateSession(input *RequestInput, updatedParams url.Values) {
+func CreateSession(input *RequestInput, updatedParams url.Values) error {
 	// Key the session by the authorization code returned by the IdP.
 	code := updatedParams.Get("code")
 	if code == "" {
 		// If there is no code, we have nothing to later key the session by in token endpoint.
 		// This likely indicates Implicit mode where tracking the session across calls isn't necessary.
-		return
+		return nil
 	}
 
 	// Get the updated redirect uri and if none, the input redirect uri.
@@ -94,14 +194,24 @@ func CreateSession(input *RequestInput, updatedParams url.Values) {
 		redirectURI = input.URLParams.Get("redirect_uri")
 	}
 
+	sessionID, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
 	session := Session{
 		ClientID:            input.URLParams.Get("client_id"),
 		Nonce:               input.URLParams.Get("nonce"),
 		CodeChallenge:       input.URLParams.Get("code_challenge"),
 		CodeChallengeMethod: input.URLParams.Get("code_challenge_method"),
 		RedirectURI:         redirectURI,
+		Scope:               input.URLParams.Get("scope"),
+		SessionID:           sessionID,
 		Code:                code,
+		Resources:           input.URLParams["resource"],
 	}
+	// Details are validated by the Authorization Endpoint before the session is created.
+	session.AuthorizationDetails, _ = ParseAuthorizationDetails(input.URLParams.Get("authorization_details"))
 
 	sessionsMutex.Lock()
 	defer sessionsMutex.Unlock()
@@ -109,6 +219,7 @@ func CreateSession(input *RequestInput, updatedParams url.Values) {
 		sessions = make(map[string]Session)
 	}
 	sessions[code] = session
+	return nil
 }
 
 // GetSession returns the Session by code key.
@@ -122,3 +233,12 @@ func GetSession(code string) (Session, error) {
 
 	return session, nil
 }
+
+// newSessionID returns a random session ID.
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -19,6 +19,7 @@ import (
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestSessionStorage(t *testing.T) {
@@ -64,15 +65,30 @@ func TestSessionStorage(t *testing.T) {
 				"code":         []string{tc.code},
 			}
 
-			CreateSession(input, updatedParams)
+			if err := CreateSession(input, updatedParams); err != nil {
+				t.Fatalf("CreateSession() failed with unexpected error: %v", err)
+			}
 			session, err := GetSession(tc.code)
 			if err != nil && tc.err == nil {
 				t.Errorf("GetSession() failed with unexpected error: %v", err)
 			}
 
+			// The session ID is random.
+			if err == nil && session.SessionID == "" {
+				t.Errorf("expected a session ID, got none")
+			}
+			session.SessionID = ""
+
 			if !reflect.DeepEqual(tc.session, session) {
 				t.Errorf("expected %v, got %v", tc.session, session)
 			}
 		})
 	}
 }
+
+func TestExpiresIn(t *testing.T) {
+	input := &RequestInput{Time: time.Unix(1000, 0)}
+	if got := input.ExpiresIn(600); got != 1600 {
+		t.Errorf("ExpiresIn(600) returned %d, expected 1600", got)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Token tracks a token issued by the Token endpoint.
+type Token struct {
+	// The token value.
+	Value string
+
+	// The token response field the token was issued in, such as refresh_token.
+	Type string
+
+	// The first token of a rotation chain. Tokens in a family are revoked together.
+	Family string
+
+	// Number of times the token has been used.
+	Uses int
+
+	// Set once the token has been replaced by a newer one.
+	Rotated bool
+
+	// Set once the token has been revoked.
+	Revoked bool
+
+	// The client the token was issued to.
+	ClientID string
+
+	// The scope the token was issued with.
+	Scope string
+
+	// Issue timestamp.
+	IssuedAt time.Time
+
+	// Expiry timestamp, zero if unknown.
+	ExpiresAt time.Time
+
+	// The DPoP key thumbprint the token is bound to, if any.
+	JKT string
+
+	// The client certificate thumbprint the token is bound to, if any.
+	X5T string
+
+	// The session the token was issued for.
+	Session Session
+}
+
+// Global map for tracking issued tokens.
+var tokens map[string]Token
+var tokensMutex sync.Mutex
+
+// AddToken adds or replaces an issued token keyed by its value.
+func AddToken(token Token) {
+	if token.Family == "" {
+		token.Family = token.Value
+	}
+
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+	if tokens == nil {
+		tokens = make(map[string]Token)
+	}
+	tokens[token.Value] = token
+}
+
+// GetToken returns the Token by value.
+func GetToken(value string) (Token, error) {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+	token, ok := tokens[value]
+	if !ok {
+		return Token{}, fmt.Errorf("no token found")
+	}
+
+	return token, nil
+}
+
+// UpdateToken calls update with the Token by value and stores the result unless
+// update returns an error. The lock is held throughout so a check and change of
+// the token can't race with another request.
+func UpdateToken(value string, update func(*Token) error) error {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+	token, ok := tokens[value]
+	if !ok {
+		return fmt.Errorf("no token found")
+	}
+
+	if err := update(&token); err != nil {
+		return err
+	}
+	tokens[value] = token
+	return nil
+}
+
+// RevokeTokenFamily revokes all tokens issued in the family.
+func RevokeTokenFamily(family string) {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+	for value, token := range tokens {
+		if token.Family == family {
+			token.Revoked = true
+			tokens[value] = token
+		}
+	}
+}
+
+// RevokeToken revokes an issued token. Revoking a refresh token revokes its whole family.
+func RevokeToken(value string) error {
+	token, err := GetToken(value)
+	if err != nil {
+		return err
+	}
+
+	if token.Type == "refresh_token" {
+		RevokeTokenFamily(token.Family)
+		return nil
+	}
+
+	token.Revoked = true
+	AddToken(token)
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTokenStorage(t *testing.T) {
+	AddToken(Token{Value: "first", Type: "refresh_token", Session: Session{Code: "code"}})
+	AddToken(Token{Value: "second", Type: "refresh_token", Family: "first"})
+	AddToken(Token{Value: "other", Type: "refresh_token"})
+
+	first, err := GetToken("first")
+	if err != nil {
+		t.Fatalf("GetToken() failed with unexpected error: %v", err)
+	}
+
+	if first.Family != "first" || first.Session.Code != "code" {
+		t.Errorf("GetToken() returned unexpected token %v", first)
+	}
+
+	if _, err := GetToken("missing"); err == nil {
+		t.Errorf("GetToken() expected an error for a missing token")
+	}
+
+	if err := UpdateToken("other", func(token *Token) error { token.Uses++; return nil }); err != nil {
+		t.Fatalf("UpdateToken() failed with unexpected error: %v", err)
+	}
+
+	if err := UpdateToken("other", func(*Token) error { return fmt.Errorf("rejected") }); err == nil {
+		t.Errorf("UpdateToken() expected the update error")
+	}
+
+	if other, _ := GetToken("other"); other.Uses != 1 {
+		t.Errorf("UpdateToken() stored %d uses, expected 1", other.Uses)
+	}
+
+	RevokeTokenFamily("first")
+	for value, wantRevoked := range map[string]bool{"first": true, "second": true, "other": false} {
+		token, err := GetToken(value)
+		if err != nil {
+			t.Fatalf("GetToken(%q) failed with unexpected error: %v", value, err)
+		}
+
+		if token.Revoked != wantRevoked {
+			t.Errorf("GetToken(%q) revoked is %v, expected %v", value, token.Revoked, wantRevoked)
+		}
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	AddToken(Token{Value: "access", Type: "access_token"})
+	AddToken(Token{Value: "refresh", Type: "refresh_token"})
+	AddToken(Token{Value: "rotated", Type: "refresh_token", Family: "refresh"})
+
+	for _, value := range []string{"access", "rotated"} {
+		if err := RevokeToken(value); err != nil {
+			t.Fatalf("RevokeToken(%q) failed with unexpected error: %v", value, err)
+		}
+	}
+
+	for _, value := range []string{"access", "refresh", "rotated"} {
+		token, err := GetToken(value)
+		if err != nil {
+			t.Fatalf("GetToken(%q) failed with unexpected error: %v", value, err)
+		}
+
+		if !token.Revoked {
+			t.Errorf("GetToken(%q) returned a token that is not revoked", value)
+		}
+	}
+
+	if err := RevokeToken("missing"); err == nil {
+		t.Errorf("RevokeToken() expected an error for a missing token")
+	}
+}
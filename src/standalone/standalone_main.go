@@ -16,6 +16,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"html/template"
 	"log"
 	"net/http"
@@ -37,6 +38,19 @@ func main() {
 		log.Printf("Defaulting to port %s", port)
 	}
 
+	// With a certificate and key configured, serve TLS and request client
+	// certificates for mutual TLS. Certificates are not verified here so that
+	// the IdP can accept or reject them per client.
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile != "" && keyFile != "" {
+		server := &http.Server{
+			Addr:      ":" + port,
+			TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
+		}
+		log.Printf("Listening with TLS on port %s", port)
+		log.Fatal(server.ListenAndServeTLS(certFile, keyFile))
+	}
+
 	log.Printf("Listening on port %s", port)
 	http.ListenAndServe(":"+port, nil)
 }